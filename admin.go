@@ -0,0 +1,222 @@
+package ssdb
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//Admin is implemented by SSDB for tooling that inspects or maintains the server
+type Admin interface {
+	Info() (*Info, error)
+	//approximate size of the data on disk in bytes
+	DBSize() (int64, error)
+	//deletes every key, confirm must be FlushDBConfirm
+	FlushDB(confirm string) error
+	//compacts the storage, it may take minutes on a large database
+	Compact() error
+	KeyRange() (*KeyRanges, error)
+	ListAllowIP() ([]string, error)
+	//only clients matching an allowed ip prefix may connect once one is added
+	AddAllowIP(ip string) error
+	DelAllowIP(ip string) error
+}
+
+//FlushDB deletes nothing unless passed this string, so a stray call
+//cannot wipe a server
+const FlushDBConfirm = "flush all data"
+
+var ErrFlushNotConfirmed = errors.New("ssdb: FlushDB not confirmed")
+
+//Info is the parsed reply of the info command
+type Info struct {
+	Version    string
+	Links      int64
+	TotalCalls int64
+	DBSize     int64
+	Binlogs    BinlogInfo
+	//one entry per replication link, empty when the server has none
+	Replication []ReplicationInfo
+	//every field of the reply in order, including those parsed above
+	Fields Pairs
+}
+
+type BinlogInfo struct {
+	Capacity int64
+	MinSeq   int64
+	MaxSeq   int64
+}
+
+//ReplicationInfo describes a master (Role "slaveof") or a slave (Role "client")
+type ReplicationInfo struct {
+	Role string
+	Addr string
+	//sync or mirror
+	Type string
+	//e.g. SYNC, COPY, OUT_OF_SYNC, DISCONNECTED
+	Status  string
+	LastSeq int64
+	//every "name: value" line of the entry
+	Fields map[string]string
+}
+
+//KeyRange is the first and last key of a data type
+type KeyRange struct {
+	Start string
+	End   string
+}
+
+type KeyRanges struct {
+	KV    KeyRange
+	Hash  KeyRange
+	Zset  KeyRange
+	Queue KeyRange
+}
+
+func (db *SSDB) Info() (*Info, error) {
+	resp, err := db.do("info")
+	if err != nil {
+		return nil, err
+	}
+	return infoReply(resp)
+}
+
+func (db *SSDB) DBSize() (int64, error) {
+	resp, err := db.do("dbsize")
+	if err != nil {
+		return 0, err
+	}
+	return Int64(resp)
+}
+
+func (db *SSDB) FlushDB(confirm string) error {
+	if confirm != FlushDBConfirm {
+		return ErrFlushNotConfirmed
+	}
+	resp, err := db.do("flushdb")
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) Compact() error {
+	resp, err := db.do("compact")
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) KeyRange() (*KeyRanges, error) {
+	resp, err := db.do("key_range")
+	if err != nil {
+		return nil, err
+	}
+	if err = checkArity(resp, 8); err != nil {
+		return nil, err
+	}
+	r := func(i int) KeyRange {
+		return KeyRange{Start: resp.String(i), End: resp.String(i + 1)}
+	}
+	return &KeyRanges{KV: r(1), Hash: r(3), Zset: r(5), Queue: r(7)}, nil
+}
+
+func (db *SSDB) ListAllowIP() ([]string, error) {
+	resp, err := db.do("list_allow_ip")
+	if err != nil {
+		return nil, err
+	}
+	return StringArray(resp)
+}
+
+func (db *SSDB) AddAllowIP(ip string) error {
+	resp, err := db.do("add_allow_ip", ip)
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) DelAllowIP(ip string) error {
+	resp, err := db.do("del_allow_ip", ip)
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+//the reply starts with a lone "ssdb-server" block, name/value pairs follow
+func infoReply(rsp *Response) (*Info, error) {
+	if err := checkStatus(rsp); err != nil {
+		return nil, err
+	}
+	first := 1
+	if (rsp.Len()-first)%2 != 0 {
+		first++
+	}
+	info := &Info{Fields: make(Pairs, 0, (rsp.Len()-first)/2)}
+	for i := first; i < rsp.Len(); i += 2 {
+		name, value := rsp.String(i), rsp.String(i+1)
+		info.Fields = append(info.Fields, Pair{Key: name, Value: value})
+		var err error
+		switch name {
+		case "version":
+			info.Version = value
+		case "links":
+			info.Links, err = parseInfoInt(value)
+		case "total_calls":
+			info.TotalCalls, err = parseInfoInt(value)
+		case "dbsize":
+			info.DBSize, err = parseInfoInt(value)
+		case "binlogs":
+			f := infoFields(value)
+			if info.Binlogs.Capacity, err = parseInfoInt(f["capacity"]); err != nil {
+				break
+			}
+			if info.Binlogs.MinSeq, err = parseInfoInt(f["min_seq"]); err != nil {
+				break
+			}
+			info.Binlogs.MaxSeq, err = parseInfoInt(f["max_seq"])
+		case "replication":
+			var r ReplicationInfo
+			if r, err = parseReplication(value); err == nil {
+				info.Replication = append(info.Replication, r)
+			}
+		}
+		if err != nil {
+			return nil, &ProtocolError{Cmd: "info", Msg: name + ": " + err.Error()}
+		}
+	}
+	return info, nil
+}
+
+func parseInfoInt(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+//parses the indented "name: value" lines of a multi-line info field
+func infoFields(value string) map[string]string {
+	m := make(map[string]string)
+	for _, line := range strings.Split(value, "\n") {
+		if name, v, found := strings.Cut(strings.TrimSpace(line), ":"); found {
+			m[strings.TrimSpace(name)] = strings.TrimSpace(v)
+		}
+	}
+	return m
+}
+
+//parses an entry like "client 127.0.0.1:8889\n    type: sync\n    status: SYNC\n    last_seq: 12"
+func parseReplication(value string) (ReplicationInfo, error) {
+	head, rest, _ := strings.Cut(value, "\n")
+	r := ReplicationInfo{Fields: infoFields(rest)}
+	r.Role, r.Addr, _ = strings.Cut(strings.TrimSpace(head), " ")
+	r.Type = r.Fields["type"]
+	r.Status = r.Fields["status"]
+	var err error
+	r.LastSeq, err = parseInfoInt(r.Fields["last_seq"])
+	return r, err
+}
@@ -0,0 +1,88 @@
+package ssdb
+
+import (
+	"github.com/jiecao-fm/ssdb/ssdbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAdmin(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	db.Set("k1", "v")
+	db.Set("k2", "v")
+	db.HSet("h", "f", "v")
+	db.QPushBack("q", "a")
+
+	info, err := db.Info()
+	assert.Nil(t, err)
+	assert.Equal(t, ssdbtest.Version, info.Version, "info version")
+	assert.Equal(t, int64(1), info.Links, "info links")
+	assert.Equal(t, int64(5), info.TotalCalls, "info total_calls")
+	assert.True(t, info.DBSize > 0, "info dbsize")
+
+	size, _ := db.DBSize()
+	assert.Equal(t, info.DBSize, size, "dbsize")
+
+	r, err := db.KeyRange()
+	assert.Nil(t, err)
+	assert.Equal(t, KeyRange{"k1", "k2"}, r.KV, "kv range")
+	assert.Equal(t, KeyRange{"h", "h"}, r.Hash, "hash range")
+	assert.Equal(t, KeyRange{}, r.Zset, "empty zset range")
+
+	assert.Nil(t, db.AddAllowIP("127.0.0.1"))
+	assert.Nil(t, db.AddAllowIP("10.0"))
+	ips, _ := db.ListAllowIP()
+	assert.Equal(t, []string{"10.0", "127.0.0.1"}, ips, "list_allow_ip")
+	db.DelAllowIP("10.0")
+	ips, _ = db.ListAllowIP()
+	assert.Equal(t, []string{"127.0.0.1"}, ips, "del_allow_ip")
+
+	assert.Nil(t, db.Compact(), "compact")
+	assert.Equal(t, ErrFlushNotConfirmed, db.FlushDB(""), "unconfirmed flushdb")
+	ex, _ := db.Exists("k1")
+	assert.True(t, ex, "unconfirmed flushdb deleted data")
+	assert.Nil(t, db.FlushDB(FlushDBConfirm), "flushdb")
+	ex, _ = db.Exists("k1")
+	assert.False(t, ex, "flushdb kept data")
+}
+
+func TestInfoReply(t *testing.T) {
+	host, port := replyServer(t, func(req []string) []string {
+		return []string{"ok", "ssdb-server",
+			"version", "1.9.9",
+			"links", "3",
+			"total_calls", "1024",
+			"dbsize", "4096",
+			"binlogs", "    capacity: 20000000\n    min_seq: 10\n    max_seq: 42",
+			"replication", "client 127.0.0.1:55000\n    type: sync\n    status: SYNC\n    last_seq: 42",
+			"replication", "slaveof 10.0.0.1:8888\n    id: m1\n    type: mirror\n    status: COPY\n    last_seq: 40\n    copy_count: 7",
+			"serv_key_range", "    kv  : \"\" - \"\"",
+		}
+	})
+	db, err := Connect(host, port, conn_timeout, read_timeout, write_timeout)
+	if err != nil {
+		t.Fatalf("connect to server failed: %v", err)
+	}
+	defer db.Close()
+
+	info, err := db.Info()
+	assert.Nil(t, err)
+	assert.Equal(t, "1.9.9", info.Version)
+	assert.Equal(t, int64(3), info.Links)
+	assert.Equal(t, int64(1024), info.TotalCalls)
+	assert.Equal(t, int64(4096), info.DBSize)
+	assert.Equal(t, BinlogInfo{Capacity: 20000000, MinSeq: 10, MaxSeq: 42}, info.Binlogs)
+	assert.Equal(t, 2, len(info.Replication), "one entry per replication link")
+	slave := info.Replication[0]
+	assert.Equal(t, "client", slave.Role)
+	assert.Equal(t, "127.0.0.1:55000", slave.Addr)
+	assert.Equal(t, "SYNC", slave.Status)
+	assert.Equal(t, int64(42), slave.LastSeq)
+	master := info.Replication[1]
+	assert.Equal(t, "slaveof", master.Role)
+	assert.Equal(t, "mirror", master.Type)
+	assert.Equal(t, "7", master.Fields["copy_count"])
+	assert.Equal(t, 8, len(info.Fields), "every field kept")
+	assert.Equal(t, "serv_key_range", info.Fields[7].Key)
+}
@@ -0,0 +1,169 @@
+package ssdb
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//Argument is implemented by types that encode themselves as a single
+//command argument, e.g. a protobuf message
+type Argument interface {
+	MarshalSSDB() ([]byte, error)
+}
+
+//appends the blocks of one command argument to buf.
+//
+//Strings and []byte are sent as is and integers, floats and bools ("1" or
+//"0") in decimal; a byte is a uint8 and goes in decimal too, only byte
+//slices and arrays are sent raw. A time.Duration is a ttl, sent like the
+//ttl of SetX in whole seconds, rounded up, and gives ErrInvalidTTL when
+//not positive. Slices, []interface{} included, are flattened into one block
+//per item and maps with string keys into key/value blocks sorted by key.
+//Other types are sent through Argument or fmt.Stringer, or by the kind
+//of their underlying type; anything else gives ErrInvalidArgument.
+func appendArg(buf []byte, arg interface{}) ([]byte, error) {
+	switch arg := arg.(type) {
+	case Argument:
+		b, err := arg.MarshalSSDB()
+		if err != nil {
+			return buf, err
+		}
+		return appendBlock(buf, b), nil
+	case string:
+		return appendBlock(buf, []byte(arg)), nil
+	case []byte:
+		return appendBlock(buf, arg), nil
+	case int:
+		return appendInt(buf, int64(arg)), nil
+	case int64:
+		return appendInt(buf, arg), nil
+	case float64:
+		return appendFloat(buf, arg, 64), nil
+	case bool:
+		return appendBool(buf, arg), nil
+	case time.Duration:
+		secs, err := ttlSeconds(arg)
+		if err != nil {
+			return buf, err
+		}
+		return appendInt(buf, secs), nil
+	case []string:
+		for _, s := range arg {
+			buf = appendBlock(buf, []byte(s))
+		}
+		return buf, nil
+	case [][]byte:
+		for _, b := range arg {
+			buf = appendBlock(buf, b)
+		}
+		return buf, nil
+	case []interface{}:
+		var err error
+		for _, a := range arg {
+			if buf, err = appendArg(buf, a); err != nil {
+				return buf, err
+			}
+		}
+		return buf, nil
+	case map[string]string:
+		for _, k := range sortedKeys(arg) {
+			buf = appendBlock(buf, []byte(k))
+			buf = appendBlock(buf, []byte(arg[k]))
+		}
+		return buf, nil
+	case map[string]int64:
+		for _, k := range sortedKeys(arg) {
+			buf = appendBlock(buf, []byte(k))
+			buf = appendInt(buf, arg[k])
+		}
+		return buf, nil
+	case map[string][]byte:
+		for _, k := range sortedKeys(arg) {
+			buf = appendBlock(buf, []byte(k))
+			buf = appendBlock(buf, arg[k])
+		}
+		return buf, nil
+	case fmt.Stringer:
+		return appendBlock(buf, []byte(arg.String())), nil
+	}
+	return appendValue(buf, reflect.ValueOf(arg))
+}
+
+//encodes the types appendArg does not list by their kind, e.g. a named
+//integer type or a slice of them
+func appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return appendBlock(buf, []byte(v.String())), nil
+	case reflect.Bool:
+		return appendBool(buf, v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendInt(buf, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var digits [20]byte
+		return appendBlock(buf, strconv.AppendUint(digits[:0], v.Uint(), 10)), nil
+	case reflect.Float32, reflect.Float64:
+		return appendFloat(buf, v.Float(), v.Type().Bits()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Kind() == reflect.Slice {
+				return appendBlock(buf, v.Bytes()), nil
+			}
+			//Bytes panics on an array passed by value
+			b := make([]byte, v.Len())
+			for i := range b {
+				b[i] = byte(v.Index(i).Uint())
+			}
+			return appendBlock(buf, b), nil
+		}
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			if buf, err = appendArg(buf, v.Index(i).Interface()); err != nil {
+				return buf, err
+			}
+		}
+		return buf, nil
+	}
+	if !v.IsValid() {
+		return buf, fmt.Errorf("%w: nil", ErrInvalidArgument)
+	}
+	return buf, fmt.Errorf("%w: unsupported type %s", ErrInvalidArgument, v.Type())
+}
+
+func appendBlock(buf []byte, b []byte) []byte {
+	buf = strconv.AppendInt(buf, int64(len(b)), 10)
+	buf = append(buf, '\n')
+	buf = append(buf, b...)
+	return append(buf, '\n')
+}
+
+//appends n as a block, formatting it on the stack
+func appendInt(buf []byte, n int64) []byte {
+	var digits [20]byte
+	return appendBlock(buf, strconv.AppendInt(digits[:0], n, 10))
+}
+
+func appendFloat(buf []byte, f float64, bits int) []byte {
+	var digits [32]byte
+	return appendBlock(buf, strconv.AppendFloat(digits[:0], f, 'f', -1, bits))
+}
+
+func appendBool(buf []byte, b bool) []byte {
+	if b {
+		return appendBlock(buf, []byte{'1'})
+	}
+	return appendBlock(buf, []byte{'0'})
+}
+
+//map keys in order, so the same map always gives the same request
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,135 @@
+package ssdb
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type userID int32
+
+type color string
+
+type uuid [16]byte
+
+type point struct{ x, y int }
+
+func (p point) String() string { return "point" }
+
+type message struct {
+	data []byte
+	err  error
+}
+
+func (m message) MarshalSSDB() ([]byte, error) { return m.data, m.err }
+
+//encodes arg and parses the blocks back
+func encodeArg(arg interface{}) ([]string, error) {
+	buf, err := appendArg(nil, arg)
+	if err != nil {
+		return nil, err
+	}
+	r := new(Response)
+	if err := r.read(bufio.NewReader(bytes.NewReader(append(buf, '\n'))), 1<<20, 1<<20); err != nil {
+		return nil, err
+	}
+	return blocks(r), nil
+}
+
+func TestAppendArg(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  interface{}
+		want []string
+	}{
+		{"string", "a\nb", []string{"a\nb"}},
+		{"bytes", []byte{0, '\n'}, []string{"\x00\n"}},
+		{"byte", byte('a'), []string{"97"}},
+		{"byte array", [3]byte{'a', 0, '\n'}, []string{"a\x00\n"}},
+		{"named byte array", uuid{15: 'z'}, []string{string(make([]byte, 15)) + "z"}},
+		{"int", -1, []string{"-1"}},
+		{"int8", int8(-8), []string{"-8"}},
+		{"int32", int32(math.MinInt32), []string{"-2147483648"}},
+		{"int64", int64(math.MaxInt64), []string{"9223372036854775807"}},
+		{"uint", uint(7), []string{"7"}},
+		{"uint16", uint16(65535), []string{"65535"}},
+		{"uint64", uint64(math.MaxUint64), []string{"18446744073709551615"}},
+		{"float64", 1.5, []string{"1.5"}},
+		{"large float64", 1e21, []string{"1000000000000000000000"}},
+		{"float32", float32(0.1), []string{"0.1"}},
+		{"true", true, []string{"1"}},
+		{"false", false, []string{"0"}},
+		{"duration", 1500 * time.Millisecond, []string{"2"}},
+		{"whole duration", time.Minute, []string{"60"}},
+		{"named int", userID(42), []string{"42"}},
+		{"named string", color("red"), []string{"red"}},
+		{"stringer", point{1, 2}, []string{"point"}},
+		{"argument", message{data: []byte("\x00pb")}, []string{"\x00pb"}},
+		{"strings", []string{"a", ""}, []string{"a", ""}},
+		{"byte slices", [][]byte{{'a'}, nil}, []string{"a", ""}},
+		{"ints", []int64{1, -2}, []string{"1", "-2"}},
+		{"interfaces", []interface{}{1, "a", []byte("b"), []string{"c", "d"}, true}, []string{"1", "a", "b", "c", "d", "1"}},
+		{"string map", map[string]string{"b": "2", "a": "1"}, []string{"a", "1", "b", "2"}},
+		{"int64 map", map[string]int64{"z": -1, "y": 2}, []string{"y", "2", "z", "-1"}},
+		{"bytes map", map[string][]byte{"k": {0}}, []string{"k", "\x00"}},
+	}
+	for _, test := range tests {
+		got, err := encodeArg(test.arg)
+		assert.Nil(t, err, test.name)
+		assert.Equal(t, test.want, got, test.name)
+	}
+
+	for _, arg := range []interface{}{nil, struct{}{}, make(chan int), map[int]string{1: "a"}, []interface{}{"a", &struct{}{}}} {
+		_, err := encodeArg(arg)
+		assert.ErrorIs(t, err, ErrInvalidArgument, "unsupported argument")
+	}
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		_, err := encodeArg(ttl)
+		assert.ErrorIs(t, err, ErrInvalidTTL, "duration not positive")
+	}
+	marshal := errors.New("marshal failed")
+	_, err := encodeArg(message{err: marshal})
+	assert.ErrorIs(t, err, marshal, "Argument error")
+}
+
+func TestSendArgs(t *testing.T) {
+	db := testDB(t)
+	_, err := db.do("set", "f", 2.5)
+	assert.Nil(t, err)
+	v, _ := db.Get("f")
+	assert.Equal(t, "2.5", v, "float argument")
+	_, err = db.do("zset", "z", "k", userID(5))
+	assert.Nil(t, err)
+	score, _ := db.ZGet("z", "k")
+	assert.Equal(t, int64(5), score, "named int argument")
+
+	//a byte is a number on the wire, unlike the raw byte sent before, see CHANGELOG.md
+	_, err = db.do("set", "b", byte(1))
+	assert.Nil(t, err)
+	v, _ = db.Get("b")
+	assert.Equal(t, "1", v, "byte argument in decimal")
+	_, err = db.do("set", "b", []byte{1})
+	assert.Nil(t, err)
+	v, _ = db.Get("b")
+	assert.Equal(t, "\x01", v, "byte slice argument raw")
+
+	_, err = db.do("set", "k", struct{}{})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+	assert.Nil(t, db.Err(), "connection still usable")
+	_, err = db.Get("k")
+	assert.ErrorIs(t, err, ErrNotFound, "nothing was sent")
+
+	p := db.Pipeline()
+	before := p.Set("a", "1")
+	bad := queue(p, statusOnly, "set", "b", make(chan int))
+	after := p.Get("a")
+	assert.Nil(t, p.Exec())
+	assert.Nil(t, before.Err())
+	assert.ErrorIs(t, bad.Err(), ErrInvalidArgument, "bad command fails alone")
+	assert.Equal(t, "1", after.Val(), "replies stay in step")
+}
@@ -0,0 +1,171 @@
+package ssdb
+
+import "time"
+
+//The Bytes variants below take and return values as []byte, which go to
+//and come from the connection without a string conversion. Keys stay
+//strings, a Go string holds any bytes including NUL and newlines.
+
+func (db *SSDB) SetBytes(key string, value []byte) error {
+	resp, err := db.do("set", key, value)
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) GetBytes(key string) ([]byte, error) {
+	resp, err := db.do("get", key)
+	if err != nil {
+		return nil, err
+	}
+	return BytesValue(resp)
+}
+
+func (db *SSDB) SetXBytes(key string, value []byte, ttl time.Duration) error {
+	secs, err := ttlSeconds(ttl)
+	if err != nil {
+		return err
+	}
+	resp, err := db.do("setx", key, value, secs)
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) GetSetBytes(key string, value []byte) ([]byte, error) {
+	resp, err := db.do("getset", key, value)
+	if err != nil {
+		return nil, err
+	}
+	return BytesValue(resp)
+}
+
+func (db *SSDB) MultiSetBytes(kvs map[string][]byte) error {
+	resp, err := db.do("multi_set", kvs)
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) MultiGetBytes(keys []string) (map[string][]byte, error) {
+	resp, err := db.do("multi_get", keys)
+	if err != nil {
+		return nil, err
+	}
+	return BytesMap(resp)
+}
+
+func (db *SSDB) HSetBytes(name, key string, value []byte) (bool, error) {
+	resp, err := db.do("hset", name, key, value)
+	if err != nil {
+		return false, err
+	}
+	return boolReply(resp)
+}
+
+func (db *SSDB) HGetBytes(name, key string) ([]byte, error) {
+	resp, err := db.do("hget", name, key)
+	if err != nil {
+		return nil, err
+	}
+	return BytesValue(resp)
+}
+
+func (db *SSDB) HGetAllBytes(name string) (map[string][]byte, error) {
+	resp, err := db.do("hgetall", name)
+	if err != nil {
+		return nil, err
+	}
+	return BytesMap(resp)
+}
+
+func (db *SSDB) MultiHSetBytes(name string, kvs map[string][]byte) error {
+	resp, err := db.do("multi_hset", name, kvs)
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) MultiHGetBytes(name string, keys []string) (map[string][]byte, error) {
+	resp, err := db.do("multi_hget", name, keys)
+	if err != nil {
+		return nil, err
+	}
+	return BytesMap(resp)
+}
+
+func (db *SSDB) QPushFrontBytes(name string, items ...[]byte) (int64, error) {
+	resp, err := db.do("qpush_front", name, items)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(resp)
+}
+
+func (db *SSDB) QPushBackBytes(name string, items ...[]byte) (int64, error) {
+	resp, err := db.do("qpush_back", name, items)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(resp)
+}
+
+func (db *SSDB) QPopFrontBytes(name string) ([]byte, error) {
+	resp, err := db.do("qpop_front", name)
+	if err != nil {
+		return nil, err
+	}
+	return BytesValue(resp)
+}
+
+func (db *SSDB) QPopBackBytes(name string) ([]byte, error) {
+	resp, err := db.do("qpop_back", name)
+	if err != nil {
+		return nil, err
+	}
+	return BytesValue(resp)
+}
+
+func (db *SSDB) QFrontBytes(name string) ([]byte, error) {
+	resp, err := db.do("qfront", name)
+	if err != nil {
+		return nil, err
+	}
+	return BytesValue(resp)
+}
+
+func (db *SSDB) QBackBytes(name string) ([]byte, error) {
+	resp, err := db.do("qback", name)
+	if err != nil {
+		return nil, err
+	}
+	return BytesValue(resp)
+}
+
+func (db *SSDB) QGetBytes(name string, index int64) ([]byte, error) {
+	resp, err := db.do("qget", name, index)
+	if err != nil {
+		return nil, err
+	}
+	return BytesValue(resp)
+}
+
+func (db *SSDB) QSetBytes(name string, index int64, value []byte) error {
+	resp, err := db.do("qset", name, index, value)
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) QRangeBytes(name string, offset, limit int) ([][]byte, error) {
+	resp, err := db.do("qrange", name, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return BytesArray(resp)
+}
@@ -0,0 +1,152 @@
+package ssdb
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//values that would break a line based or C string based encoding
+var binaryValues = [][]byte{
+	{0},
+	[]byte("a\nb\n\n"),
+	[]byte("\r\n0\n\n"),
+	{0xff, 0, '\n', 0xfe, 0},
+	[]byte("5\nhello\n"),
+}
+
+func TestBytes(t *testing.T) {
+	db := testDB(t)
+	key := "k\x00\n\n1"
+	for _, v := range binaryValues {
+		assert.Nil(t, db.SetBytes(key, v))
+		got, err := db.GetBytes(key)
+		assert.Nil(t, err)
+		assert.Equal(t, v, got, "value round trip")
+		s, _ := db.Get(key)
+		assert.Equal(t, string(v), s, "string view of the same value")
+	}
+	_, err := db.GetBytes("missing\x00")
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	old, err := db.GetSetBytes(key, []byte("\x00new"))
+	assert.Nil(t, err)
+	assert.Equal(t, binaryValues[len(binaryValues)-1], old)
+	assert.Nil(t, db.SetXBytes("x\n", []byte{0, 1}, time.Minute))
+	ttl, _ := db.TTL("x\n")
+	assert.True(t, ttl > 0 && ttl <= time.Minute, "ttl set")
+
+	kvs := map[string][]byte{"m\x001": binaryValues[0], "m\n2": binaryValues[1], "m\r\n3": binaryValues[3]}
+	assert.Nil(t, db.MultiSetBytes(kvs))
+	m, err := db.MultiGetBytes([]string{"m\x001", "m\n2", "m\r\n3"})
+	assert.Nil(t, err)
+	assert.Equal(t, kvs, m)
+}
+
+func TestHashBytes(t *testing.T) {
+	db := testDB(t)
+	name := "h\x00\n"
+	created, err := db.HSetBytes(name, "f\n\x00", binaryValues[3])
+	assert.Nil(t, err)
+	assert.True(t, created)
+	v, err := db.HGetBytes(name, "f\n\x00")
+	assert.Nil(t, err)
+	assert.Equal(t, binaryValues[3], v)
+
+	kvs := map[string][]byte{"a\n": binaryValues[1], "b\x00": binaryValues[2]}
+	assert.Nil(t, db.MultiHSetBytes(name, kvs))
+	m, err := db.MultiHGetBytes(name, []string{"a\n", "b\x00"})
+	assert.Nil(t, err)
+	assert.Equal(t, kvs, m)
+	all, err := db.HGetAllBytes(name)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(all))
+	assert.Equal(t, binaryValues[3], all["f\n\x00"])
+}
+
+func TestQueueBytes(t *testing.T) {
+	db := testDB(t)
+	name := "q\n\x00"
+	n, err := db.QPushBackBytes(name, binaryValues...)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(binaryValues)), n)
+	n, _ = db.QPushFrontBytes(name, []byte("\n"))
+	assert.Equal(t, int64(len(binaryValues)+1), n)
+
+	items, err := db.QRangeBytes(name, 1, len(binaryValues))
+	assert.Nil(t, err)
+	assert.Equal(t, binaryValues, items)
+	front, _ := db.QFrontBytes(name)
+	assert.Equal(t, []byte("\n"), front)
+	back, _ := db.QBackBytes(name)
+	assert.Equal(t, binaryValues[len(binaryValues)-1], back)
+	assert.Nil(t, db.QSetBytes(name, 1, []byte("\x00\x00")))
+	v, _ := db.QGetBytes(name, 1)
+	assert.Equal(t, []byte("\x00\x00"), v)
+
+	v, err = db.QPopFrontBytes(name)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("\n"), v)
+	v, err = db.QPopBackBytes(name)
+	assert.Nil(t, err)
+	assert.Equal(t, binaryValues[len(binaryValues)-1], v)
+}
+
+func TestPipelineBytes(t *testing.T) {
+	db := testDB(t)
+	p := db.Pipeline()
+	p.SetBytes("p\n", binaryValues[1])
+	get := p.GetBytes("p\n")
+	multi := p.MultiGetBytes([]string{"p\n"})
+	p.HSetBytes("ph", "f\x00", binaryValues[0])
+	hget := p.HGetBytes("ph", "f\x00")
+	push := p.QPushBackBytes("pq", binaryValues[2], binaryValues[3])
+	pop := p.QPopFrontBytes("pq")
+	assert.Nil(t, p.Exec())
+	assert.Equal(t, binaryValues[1], get.Val())
+	assert.Equal(t, map[string][]byte{"p\n": binaryValues[1]}, multi.Val())
+	assert.Equal(t, binaryValues[0], hget.Val())
+	assert.Equal(t, int64(2), push.Val())
+	assert.Equal(t, binaryValues[2], pop.Val())
+}
+
+func TestPipelineBytesAll(t *testing.T) {
+	db := testDB(t)
+	p := db.Pipeline()
+	kvs := map[string][]byte{"a\x00": binaryValues[0], "b\n": binaryValues[4]}
+	setx := p.SetXBytes("x\n", binaryValues[1], time.Minute)
+	getset := p.GetSetBytes("x\n", binaryValues[2])
+	mset := p.MultiSetBytes(kvs)
+	mget := p.MultiGetBytes([]string{"a\x00", "b\n"})
+	hset := p.MultiHSetBytes("ph\n", kvs)
+	hget := p.MultiHGetBytes("ph\n", []string{"a\x00", "b\n"})
+	hall := p.HGetAllBytes("ph\n")
+	p.QPushBackBytes("pq", binaryValues[3])
+	pushFront := p.QPushFrontBytes("pq", binaryValues[0], binaryValues[1])
+	front := p.QFrontBytes("pq")
+	back := p.QBackBytes("pq")
+	qset := p.QSetBytes("pq", 1, binaryValues[4])
+	qget := p.QGetBytes("pq", 1)
+	qrange := p.QRangeBytes("pq", 0, 10)
+	popBack := p.QPopBackBytes("pq")
+	badTTL := p.SetXBytes("x\n", nil, 0)
+	assert.Nil(t, p.Exec())
+
+	assert.Nil(t, setx.Err())
+	assert.Equal(t, binaryValues[1], getset.Val())
+	assert.Nil(t, mset.Err())
+	assert.Equal(t, kvs, mget.Val())
+	assert.Nil(t, hset.Err())
+	assert.Equal(t, kvs, hget.Val())
+	assert.Equal(t, kvs, hall.Val())
+	assert.Equal(t, int64(3), pushFront.Val())
+	assert.Equal(t, binaryValues[1], front.Val())
+	assert.Equal(t, binaryValues[3], back.Val())
+	assert.Nil(t, qset.Err())
+	assert.Equal(t, binaryValues[4], qget.Val())
+	assert.Equal(t, [][]byte{binaryValues[1], binaryValues[4], binaryValues[3]}, qrange.Val())
+	assert.Equal(t, binaryValues[3], popBack.Val())
+	assert.ErrorIs(t, badTTL.Err(), ErrInvalidTTL)
+}
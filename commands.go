@@ -0,0 +1,41 @@
+package ssdb
+
+//kind of every command sent by Client and Admin, keyed by protocol name
+var command_kinds = map[string]CommandKind{
+	"ping": ReadCommand, "info": ReadCommand, "dbsize": ReadCommand,
+	"key_range": ReadCommand, "list_allow_ip": ReadCommand,
+	"flushdb": IdempotentWrite, "compact": IdempotentWrite,
+	"add_allow_ip": IdempotentWrite, "del_allow_ip": IdempotentWrite,
+
+	"get": ReadCommand, "exists": ReadCommand, "keys": ReadCommand, "scan": ReadCommand,
+	"rscan": ReadCommand, "multi_get": ReadCommand, "ttl": ReadCommand, "getbit": ReadCommand,
+	"bitcount": ReadCommand, "countbit": ReadCommand, "substr": ReadCommand, "strlen": ReadCommand,
+	"set": IdempotentWrite, "setx": IdempotentWrite, "del": IdempotentWrite, "expire": IdempotentWrite,
+	"setbit": IdempotentWrite, "multi_set": IdempotentWrite, "multi_del": IdempotentWrite,
+	//their reply tells whether this call wrote, a retry would get it wrong
+	"setnx": NonIdempotentWrite, "getset": NonIdempotentWrite,
+	"incr": NonIdempotentWrite,
+
+	"hget": ReadCommand, "hexists": ReadCommand, "hsize": ReadCommand, "hlist": ReadCommand,
+	"hrlist": ReadCommand, "hkeys": ReadCommand, "hgetall": ReadCommand, "hscan": ReadCommand,
+	"hrscan": ReadCommand, "multi_hget": ReadCommand,
+	"hset": IdempotentWrite, "hdel": IdempotentWrite, "hclear": IdempotentWrite,
+	"multi_hset": IdempotentWrite, "multi_hdel": IdempotentWrite,
+	"hincr": NonIdempotentWrite,
+
+	"zget": ReadCommand, "zexists": ReadCommand, "zsize": ReadCommand, "zlist": ReadCommand,
+	"zrlist": ReadCommand, "zkeys": ReadCommand, "zscan": ReadCommand, "zrscan": ReadCommand,
+	"zcount": ReadCommand, "zrank": ReadCommand, "zrrank": ReadCommand, "zrange": ReadCommand,
+	"zrrange": ReadCommand, "zsum": ReadCommand, "zavg": ReadCommand, "multi_zget": ReadCommand,
+	"zset": IdempotentWrite, "zdel": IdempotentWrite, "zclear": IdempotentWrite,
+	"multi_zset": IdempotentWrite, "multi_zdel": IdempotentWrite, "zremrangebyscore": IdempotentWrite,
+	"zincr": NonIdempotentWrite, "zremrangebyrank": NonIdempotentWrite,
+	"zpop_front": NonIdempotentWrite, "zpop_back": NonIdempotentWrite,
+
+	"qsize": ReadCommand, "qlist": ReadCommand, "qrlist": ReadCommand, "qfront": ReadCommand,
+	"qback": ReadCommand, "qget": ReadCommand, "qslice": ReadCommand, "qrange": ReadCommand,
+	"qset": IdempotentWrite, "qclear": IdempotentWrite, "qfix": IdempotentWrite,
+	"qpush_front": NonIdempotentWrite, "qpush_back": NonIdempotentWrite,
+	"qpop_front": NonIdempotentWrite, "qpop_back": NonIdempotentWrite,
+	"qtrim_front": NonIdempotentWrite, "qtrim_back": NonIdempotentWrite,
+}
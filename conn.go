@@ -3,11 +3,12 @@ package ssdb
 //Conn impl
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net"
-	"strconv"
+	"sync/atomic"
+	"time"
 
 	//"encoding/binary"
 )
@@ -18,12 +19,86 @@ type conn struct {
 	reader    *bufio.Reader
 	writer    *bufio.Writer
 	err       error
-	recv_buf  bytes.Buffer
+	//reused by every reply, see Response
+	resp *Response
+	//reused by every request
+	wbuf []byte
+	//limits of a single block and of a whole reply, 0 means the default
+	maxblock    int
+	maxresponse int
+
+	readtimeout  time.Duration
+	writetimeout time.Duration
+	//deadline of the context bound to the in-flight request
+	ctxdeadline time.Time
+	//set once the in-flight request has been aborted by its context
+	aborted atomic.Bool
+}
+
+//TimeoutError is returned when a read or write does not complete within
+//the read or write timeout of the connection.
+type TimeoutError struct {
+	Op  string
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return "ssdb: " + e.Op + " timeout: " + e.Err.Error()
+}
+
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+func wrapTimeout(op string, err error) error {
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return &TimeoutError{Op: op, Err: err}
+	}
+	return err
+}
+
+//computes the deadline of the next read or write, the earlier of the
+//operation timeout and the deadline of the bound context
+func (c *conn) deadline(timeout time.Duration) time.Time {
+	var d time.Time
+	if timeout > 0 {
+		d = time.Now().Add(timeout)
+	}
+	if !c.ctxdeadline.IsZero() && (d.IsZero() || c.ctxdeadline.Before(d)) {
+		d = c.ctxdeadline
+	}
+	return d
+}
+
+func (c *conn) setReadDeadline() {
+	c.client.SetReadDeadline(c.deadline(c.readtimeout))
+	if c.aborted.Load() {
+		c.client.SetReadDeadline(aLongTimeAgo)
+	}
+}
+
+func (c *conn) setWriteDeadline() {
+	c.client.SetWriteDeadline(c.deadline(c.writetimeout))
+	if c.aborted.Load() {
+		c.client.SetWriteDeadline(aLongTimeAgo)
+	}
 }
 
 func (c *conn) Close() error {
 	c.client.Close()
 	c.connected = false
+	if c.err == nil {
+		c.err = errors.New("closed")
+	}
+	if c.resp != nil {
+		c.resp.reset()
+		response_pool.Put(c.resp)
+		c.resp = nil
+	}
 	return nil
 }
 
@@ -31,162 +106,130 @@ func (c *conn) Err() error {
 	return c.err
 }
 
-func (c *conn) Do(cmd string, args []interface{}) (rsp []bytes.Buffer, err error) {
+func (c *conn) Do(cmd string, args []interface{}) (rsp *Response, err error) {
 	if c.Err() != nil {
-		return make([]bytes.Buffer, 0), errors.New("broken")
+		return nil, ErrBroken
 	}
 	err = c.Send(cmd, args[:])
 	if err != nil {
-		c.err = err
-		c.connected = false
 		return nil, err
 	}
-	c.err = c.Flush()
-	if c.err != nil {
-		return make([]bytes.Buffer, 0), c.err
+	err = c.Flush()
+	if err != nil {
+		return nil, err
+	}
+	return c.Receive()
+}
+
+//a deadline in the past, used to unblock pending reads and writes
+var aLongTimeAgo = time.Unix(1, 0)
+
+func (c *conn) DoContext(ctx context.Context, cmd string, args []interface{}) (rsp *Response, err error) {
+	if c.Err() != nil {
+		return nil, ErrBroken
+	}
+	err = c.withContext(ctx, func() error {
+		rsp, err = c.Do(cmd, args)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+//runs fn, a sequence of Send/Flush/Receive calls, bound to ctx
+func (c *conn) withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	deadline, hasDeadline := ctx.Deadline()
+	c.ctxdeadline = deadline
+	defer func() { c.ctxdeadline = time.Time{} }()
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		c.aborted.Store(true)
+		c.client.SetDeadline(aLongTimeAgo)
+		close(interrupted)
+	})
+	err := fn()
+	if !stop() {
+		<-interrupted
+		if c.err == nil {
+			//fn completed, or failed without a read or write such as on an
+			//argument that can not be encoded, so the stream is intact
+			c.aborted.Store(false)
+			c.client.SetDeadline(time.Time{})
+			return err
+		}
+		//the request may be half written or half read, the stream can not be reused
+		c.broken(ctx.Err())
+		return c.err
+	}
+	var ne net.Error
+	if err != nil && hasDeadline && !time.Now().Before(deadline) && errors.As(err, &ne) && ne.Timeout() {
+		err = context.DeadlineExceeded
+		c.broken(err)
 	}
-	rsp, c.err = c.Receive()
-	return rsp[:], c.err
+	return err
 }
 
+//encodes the command and writes it to the output buffer. When an argument
+//can not be encoded nothing is written and the connection stays usable.
 func (c *conn) Send(cmd string, args []interface{}) error {
-	var buf bytes.Buffer
-	buf.WriteString(fmt.Sprintf("%d", len(cmd)))
-	buf.WriteByte('\n')
-	buf.WriteString(cmd)
-	buf.WriteByte('\n')
+	buf := appendBlock(c.wbuf[:0], []byte(cmd))
 	for _, arg := range args {
-		var block []byte
-		switch arg := arg.(type) {
-		case int:
-			{
-				s := strconv.Itoa(arg)
-				block = []byte(s)
-				writeBlock(&buf, block)
-			}
-		case int64:
-			{
-				s := strconv.FormatInt(arg, 10)
-				block = []byte(s)
-				writeBlock(&buf, block)
-			}
-		case string:
-			{
-				block = []byte(arg)
-				writeBlock(&buf, block)
-			}
-
-		case byte:
-			{
-				block = []byte{arg}
-				writeBlock(&buf, block)
-			}
-
-		case []byte:
-			{
-				writeBlock(&buf, arg)
-			}
-
-		case []string:
-			{
-				for _, a := range arg {
-					buf.WriteString(fmt.Sprintf("%d", len(a)))
-					buf.WriteByte('\n')
-					buf.WriteString(a)
-					buf.WriteByte('\n')
-				}
-
-			}
-		case float64:
-			{
-
-			}
-		case bool:
-			{
-
-			}
+		var err error
+		if buf, err = appendArg(buf, arg); err != nil {
+			return fmt.Errorf("ssdb: %s: %w", cmd, err)
 		}
 	}
-	buf.WriteByte('\n')
-	//	fmt.Printf(buf.String() + "\n")
-	_, err := c.writer.Write(buf.Bytes())
+	buf = append(buf, '\n')
+	if cap(buf) <= max_retained_buffer {
+		c.wbuf = buf
+	}
+	//the buffered writer flushes to the socket once it is full
+	c.setWriteDeadline()
+	_, err := c.writer.Write(buf)
 	if err != nil {
-		fmt.Printf("error:%v", err)
+		c.broken(wrapTimeout("write", err))
+		return c.err
 	}
-	return err
+	return nil
 }
 
-func writeBlock(buf *bytes.Buffer, bs []byte) {
-	buf.WriteString(fmt.Sprintf("%d", len(bs)))
-	buf.WriteByte('\n')
-	buf.Write(bs)
-	buf.WriteByte('\n')
+//marks the connection broken, the stream can not be reused after a failed read or write
+func (c *conn) broken(err error) {
+	c.err = err
+	c.connected = false
 }
 
 //flushes the output buffer to the server
 func (c *conn) Flush() error {
-	return c.writer.Flush()
-}
-
-//receives a single reply from server
-func (c *conn) Receive() (res []bytes.Buffer, err error) {
-	var bufArray = []bytes.Buffer{} //make([]bytes.Buffer,5)
-
-	for {
-		var sizebuf bytes.Buffer
-		//read size
-		for b, er := c.reader.ReadByte(); b != '\n'; b, er = c.reader.ReadByte() {
-			if er != nil {
-				fmt.Printf("%v\n", er)
-				return nil, er
-			}
-			if b != '\r' {
-				sizebuf.WriteByte(b)
-			}
-		}
-		//end of packet
-		if sizebuf.Len() == 0 {
-			return bufArray[0:], nil
-		}
-
-		size, er := strconv.Atoi(sizebuf.String())
-		if er != nil {
-			return nil, er
-		}
-
-		var dataBuf bytes.Buffer
-		err := readFully(c.reader, size, &dataBuf)
-		if err != nil {
-			c.err = err
-			return nil, err
-		}
-		//read \r\n
-		for b, er := c.reader.ReadByte(); b != '\n'; b, er = c.reader.ReadByte() {
-			if er != nil {
-				fmt.Printf("%v\n", er)
-			}
-		}
-		bufArray = append(bufArray, dataBuf)
+	c.setWriteDeadline()
+	if err := c.writer.Flush(); err != nil {
+		c.broken(wrapTimeout("write", err))
+		return c.err
 	}
-
-	//never execute here
-	fmt.Printf("buf size:%d\n", len(bufArray))
-	return bufArray[0:], nil
+	return nil
 }
 
-func readFully(reader *bufio.Reader, size int, buffer *bytes.Buffer) error {
-	buf := make([]byte, size)
-	count, err := reader.Read(buf)
-	if err != nil {
-		return err
+//receives a single reply from server, valid until the next Receive
+func (c *conn) Receive() (*Response, error) {
+	c.setReadDeadline()
+	if c.resp == nil {
+		c.resp = response_pool.Get().(*Response)
 	}
-
-	if count == size {
-		buffer.Write(buf)
-		return nil
-	} else {
-		buffer.Write(buf[:count])
-		readFully(reader, size-count, buffer)
+	maxblock, maxresponse := c.maxblock, c.maxresponse
+	if maxblock <= 0 {
+		maxblock = default_max_block_size
 	}
-	return nil
+	if maxresponse <= 0 {
+		maxresponse = default_max_response_size
+	}
+	if err := c.resp.read(c.reader, maxblock, maxresponse); err != nil {
+		c.broken(wrapTimeout("read", err))
+		return nil, c.err
+	}
+	return c.resp, nil
 }
@@ -0,0 +1,111 @@
+package ssdb
+
+import (
+	"errors"
+)
+
+//Error is the sentinel error of a non "ok" reply status, use errors.Is
+//to tell them apart
+type Error string
+
+func (err Error) Error() string {
+	return "ssdb: " + string(err)
+}
+
+const (
+	//the key, field or queue item does not exist
+	ErrNotFound Error = "not_found"
+	//the server rejected the request, e.g. wrong arguments
+	ErrClientError Error = "client_error"
+	//the server failed to execute the request, status "error" or "fail"
+	ErrServerError Error = "error"
+	//the server requires a password, see ConnectAuth and PoolConfig.Password
+	ErrNoAuth Error = "noauth"
+)
+
+//returned by commands on a connection that failed before
+var ErrBroken = errors.New("ssdb: connection is broken")
+
+//returned without contacting the server for an argument Send can not encode
+var ErrInvalidArgument = errors.New("ssdb: invalid argument")
+
+//returned without contacting the server when a ttl is not positive
+var ErrInvalidTTL = errors.New("ssdb: ttl must be positive")
+
+//CommandError is returned when the server answers a command with a
+//status other than "ok". It unwraps to ErrNotFound, ErrClientError,
+//ErrNoAuth or ErrServerError.
+type CommandError struct {
+	Cmd    string
+	Status string
+	//message following the status, if any
+	Msg string
+}
+
+func (e *CommandError) Error() string {
+	s := "ssdb: "
+	if e.Cmd != "" {
+		s += e.Cmd + ": "
+	}
+	s += e.Status
+	if e.Msg != "" {
+		s += ": " + e.Msg
+	}
+	return s
+}
+
+func (e *CommandError) Unwrap() error {
+	switch e.Status {
+	case string(ErrNotFound):
+		return ErrNotFound
+	case string(ErrClientError):
+		return ErrClientError
+	case string(ErrNoAuth):
+		return ErrNoAuth
+	}
+	return ErrServerError
+}
+
+//AuthError is returned when the server rejects the password of a new
+//connection. It unwraps to the *CommandError of the auth command.
+type AuthError struct {
+	Addr string
+	Err  error
+}
+
+func (e *AuthError) Error() string {
+	return "ssdb: auth to " + e.Addr + " failed: " + e.Err.Error()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+//ProtocolError reports a reply that does not follow the SSDB protocol
+type ProtocolError struct {
+	Cmd string
+	Msg string
+}
+
+func (e *ProtocolError) Error() string {
+	if e.Cmd != "" {
+		return "ssdb: protocol error: " + e.Cmd + ": " + e.Msg
+	}
+	return "ssdb: protocol error: " + e.Msg
+}
+
+//returns nil for an "ok" reply, else a *CommandError or *ProtocolError
+func statusError(cmd string, rsp *Response) error {
+	if rsp.Len() == 0 {
+		return &ProtocolError{Cmd: cmd, Msg: "empty reply"}
+	}
+	status := rsp.String(0)
+	if status == "ok" {
+		return nil
+	}
+	e := &CommandError{Cmd: cmd, Status: status}
+	if rsp.Len() > 1 {
+		e.Msg = rsp.String(1)
+	}
+	return e
+}
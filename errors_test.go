@@ -0,0 +1,53 @@
+package ssdb
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCommandError(t *testing.T) {
+	host, port := replyServer(t, func(req []string) []string {
+		switch req[0] {
+		case "get":
+			return []string{"not_found"}
+		case "set":
+			return []string{"client_error", "wrong number of arguments"}
+		case "incr":
+			return []string{"fail"}
+		}
+		return []string{"error"}
+	})
+	db, err := Connect(host, port, conn_timeout, read_timeout, write_timeout)
+	if err != nil {
+		t.Fatalf("connect to server failed: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Get("key1")
+	assert.True(t, errors.Is(err, ErrNotFound), "not_found not mapped")
+	var ce *CommandError
+	assert.True(t, errors.As(err, &ce), "not a command error")
+	assert.Equal(t, "get", ce.Cmd, "command name missing")
+	assert.Equal(t, "ssdb: get: not_found", err.Error())
+
+	err = db.Set("key1", "value1")
+	assert.True(t, errors.Is(err, ErrClientError), "client_error not mapped")
+	assert.Equal(t, "ssdb: set: client_error: wrong number of arguments", err.Error())
+
+	_, err = db.Incr("key1", 1)
+	assert.True(t, errors.Is(err, ErrServerError), "fail not mapped")
+	_, err = db.Del("key1")
+	assert.True(t, errors.Is(err, ErrServerError), "error not mapped")
+	assert.False(t, errors.Is(err, ErrNotFound), "error mapped to not_found")
+
+	p := db.Pipeline()
+	r := p.Get("key1")
+	p.Exec()
+	assert.True(t, errors.As(r.Err(), &ce), "pipeline error not a command error")
+	assert.Equal(t, "get", ce.Cmd, "pipeline command name missing")
+
+	_, err = StringValue(nil)
+	var pe *ProtocolError
+	assert.True(t, errors.As(err, &pe), "empty reply not a protocol error")
+}
@@ -1,84 +1,229 @@
 package ssdb
 
 import (
-	"bytes"
 	"fmt"
 	"strconv"
+	"time"
 )
 
-func BoolValue(rsp []bytes.Buffer) (bool, error) {
-	if rsp[0].String() != "ok" {
-		return false, fmt.Errorf(rsp[0].String())
+//The decoders below turn a reply into a Go value. Each one checks the
+//status and the number of blocks, so a malformed reply is reported as a
+//*ProtocolError instead of a panic or a zero value.
+
+func checkStatus(rsp *Response) error {
+	return statusError("", rsp)
+}
+
+//checks the status and that exactly n blocks follow it
+func checkArity(rsp *Response, n int) error {
+	if err := checkStatus(rsp); err != nil {
+		return err
+	}
+	if rsp.Len()-1 != n {
+		return &ProtocolError{Msg: fmt.Sprintf("expected %d blocks in reply, got %d", n, rsp.Len()-1)}
+	}
+	return nil
+}
+
+//checks the status and that the blocks following it come in pairs
+func checkPairs(rsp *Response) error {
+	if err := checkStatus(rsp); err != nil {
+		return err
+	}
+	if (rsp.Len()-1)%2 != 0 {
+		return &ProtocolError{Msg: fmt.Sprintf("expected key/value pairs in reply, got %d blocks", rsp.Len()-1)}
+	}
+	return nil
+}
+
+func parseInt64(block []byte) (int64, error) {
+	res, err := strconv.ParseInt(string(block), 10, 64)
+	if err != nil {
+		return 0, &ProtocolError{Msg: "invalid integer " + strconv.Quote(string(block))}
+	}
+	return res, nil
+}
+
+//copies block i out of the reused buffer of rsp
+func copyBlock(rsp *Response, i int) []byte {
+	return append([]byte(nil), rsp.Block(i)...)
+}
+
+//decodes an "ok" reply with no meaningful payload
+func statusOnly(rsp *Response) (struct{}, error) {
+	return struct{}{}, checkStatus(rsp)
+}
+
+//decodes the "1"/"0" reply of exists, hset, hdel, zdel and the like
+func boolReply(rsp *Response) (bool, error) {
+	if err := checkArity(rsp, 1); err != nil {
+		return false, err
+	}
+	switch rsp.String(1) {
+	case "1":
+		return true, nil
+	case "0":
+		return false, nil
+	}
+	return false, &ProtocolError{Msg: "invalid boolean " + strconv.Quote(rsp.String(1))}
+}
+
+//reports whether the reply status is "ok", whatever follows it
+func BoolValue(rsp *Response) (bool, error) {
+	if err := checkStatus(rsp); err != nil {
+		return false, err
 	}
 
 	return true, nil
 }
-func Int64(rsp []bytes.Buffer) (int64, error) {
-	if rsp[0].String() != "ok" {
-		return 0, fmt.Errorf(rsp[0].String())
+func Int64(rsp *Response) (int64, error) {
+	if err := checkArity(rsp, 1); err != nil {
+		return 0, err
 	}
-	res, er := strconv.ParseInt(rsp[1].String(), 10, 64)
-	return res, er
+	return parseInt64(rsp.Block(1))
 }
 
-func IntValue(rsp []bytes.Buffer) (int, error) {
-	if rsp[0].String() != "ok" {
-		return 0, fmt.Errorf(rsp[0].String())
+func IntValue(rsp *Response) (int, error) {
+	if err := checkArity(rsp, 1); err != nil {
+		return 0, err
 	}
-	return strconv.Atoi(rsp[1].String())
+	res, err := parseInt64(rsp.Block(1))
+	return int(res), err
 
 }
 
-func StringValue(rsp []bytes.Buffer) (string, error) {
-	if rsp[0].String() != "ok" {
-		return "", fmt.Errorf(rsp[0].String())
+func StringValue(rsp *Response) (string, error) {
+	if err := checkArity(rsp, 1); err != nil {
+		return "", err
 	}
 
-	return rsp[1].String(), nil
+	return rsp.String(1), nil
 }
-func StringArray(rsp []bytes.Buffer) ([]string, error) {
-	if rsp[0].String() != "ok" {
-		return nil, fmt.Errorf(rsp[0].String())
+func StringArray(rsp *Response) ([]string, error) {
+	if err := checkStatus(rsp); err != nil {
+		return nil, err
 	}
-	var res []string
-	for _, buf := range rsp[1:] {
-		res = append(res, buf.String())
+	res := make([]string, 0, rsp.Len()-1)
+	for i := 1; i < rsp.Len(); i++ {
+		res = append(res, rsp.String(i))
 	}
 
 	return res, nil
 }
 
-func Int64Map(rsp []bytes.Buffer) (map[string]int64, error) {
-	if rsp[0].String() != "ok" {
-		return nil, fmt.Errorf(rsp[0].String())
+//returns the block of a single value reply, NUL and newline bytes included
+func BytesValue(rsp *Response) ([]byte, error) {
+	if err := checkArity(rsp, 1); err != nil {
+		return nil, err
+	}
+	return copyBlock(rsp, 1), nil
+}
+
+func BytesArray(rsp *Response) ([][]byte, error) {
+	if err := checkStatus(rsp); err != nil {
+		return nil, err
+	}
+	res := make([][]byte, 0, rsp.Len()-1)
+	for i := 1; i < rsp.Len(); i++ {
+		res = append(res, copyBlock(rsp, i))
+	}
+	return res, nil
+}
+
+//converts ttl to the whole seconds SSDB expects, rounding up so a key
+//never expires earlier than asked
+func ttlSeconds(ttl time.Duration) (int64, error) {
+	if ttl <= 0 {
+		return 0, ErrInvalidTTL
+	}
+	return int64((ttl + time.Second - 1) / time.Second), nil
+}
+
+//decodes the reply of ttl, -1 becomes NoTTL
+func ttlReply(rsp *Response) (time.Duration, error) {
+	n, err := Int64(rsp)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return NoTTL, nil
 	}
-	m := make(map[string]int64)
-	key := ""
-	for _, buf := range rsp[1:] {
-		if key == "" {
-			key = buf.String()
-		} else {
-			m[key], _ = strconv.ParseInt(buf.String(), 10, 64)
-			key = ""
+	return time.Duration(n) * time.Second, nil
+}
+
+func Int64Map(rsp *Response) (map[string]int64, error) {
+	if err := checkPairs(rsp); err != nil {
+		return nil, err
+	}
+	m := make(map[string]int64, (rsp.Len()-1)/2)
+	for i := 1; i < rsp.Len(); i += 2 {
+		v, err := parseInt64(rsp.Block(i + 1))
+		if err != nil {
+			return nil, err
 		}
+		m[rsp.String(i)] = v
 	}
 	return m, nil
 }
 
-func StringMap(rsp []bytes.Buffer) (map[string]string, error) {
+//decodes key/value pairs keeping their order
+func pairReply(rsp *Response) (Pairs, error) {
+	if err := checkPairs(rsp); err != nil {
+		return nil, err
+	}
+	res := make(Pairs, 0, (rsp.Len()-1)/2)
+	for i := 1; i < rsp.Len(); i += 2 {
+		res = append(res, Pair{Key: rsp.String(i), Value: rsp.String(i + 1)})
+	}
+	return res, nil
+}
 
-	if rsp[0].String() != "ok" {
-		return nil, fmt.Errorf(rsp[0].String())
+//decodes key/score pairs keeping their order
+func zpairReply(rsp *Response) (ZPairs, error) {
+	if err := checkPairs(rsp); err != nil {
+		return nil, err
 	}
-	m := make(map[string]string)
-	key := ""
-	for _, buf := range rsp[1:] {
-		if key == "" {
-			key = buf.String()
-		} else {
-			m[key] = buf.String()
-			key = ""
+	res := make(ZPairs, 0, (rsp.Len()-1)/2)
+	for i := 1; i < rsp.Len(); i += 2 {
+		score, err := parseInt64(rsp.Block(i + 1))
+		if err != nil {
+			return nil, err
 		}
+		res = append(res, ZPair{Key: rsp.String(i), Score: score})
+	}
+	return res, nil
+}
+
+func floatReply(rsp *Response) (float64, error) {
+	if err := checkArity(rsp, 1); err != nil {
+		return 0, err
+	}
+	res, err := strconv.ParseFloat(rsp.String(1), 64)
+	if err != nil {
+		return 0, &ProtocolError{Msg: "invalid number " + strconv.Quote(rsp.String(1))}
+	}
+	return res, nil
+}
+
+func StringMap(rsp *Response) (map[string]string, error) {
+
+	if err := checkPairs(rsp); err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, (rsp.Len()-1)/2)
+	for i := 1; i < rsp.Len(); i += 2 {
+		m[rsp.String(i)] = rsp.String(i + 1)
+	}
+	return m, nil
+}
+
+func BytesMap(rsp *Response) (map[string][]byte, error) {
+	if err := checkPairs(rsp); err != nil {
+		return nil, err
+	}
+	m := make(map[string][]byte, (rsp.Len()-1)/2)
+	for i := 1; i < rsp.Len(); i += 2 {
+		m[rsp.String(i)] = copyBlock(rsp, i+1)
 	}
 	return m, nil
 }
@@ -0,0 +1,112 @@
+package ssdb
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	var lock sync.Mutex
+	var reply []string
+	var got []string
+	host, port := replyServer(t, func(req []string) []string {
+		lock.Lock()
+		defer lock.Unlock()
+		got = req
+		return reply
+	})
+	db, err := Connect(host, port, conn_timeout, read_timeout, write_timeout)
+	if err != nil {
+		t.Fatalf("connect to server failed: %v", err)
+	}
+	defer db.Close()
+
+	protocolError := &ProtocolError{}
+	tests := []struct {
+		name  string
+		reply []string
+		call  func() (interface{}, error)
+		req   []string
+		want  interface{}
+		err   interface{}
+	}{
+		{"set", []string{"ok", "1"}, func() (interface{}, error) { return nil, db.Set("k", "v") }, []string{"set", "k", "v"}, nil, nil},
+		{"set error", []string{"error"}, func() (interface{}, error) { return nil, db.Set("k", "v") }, nil, nil, ErrServerError},
+		{"get", []string{"ok", "v"}, func() (interface{}, error) { return db.Get("k") }, []string{"get", "k"}, "v", nil},
+		{"get not found", []string{"not_found"}, func() (interface{}, error) { return db.Get("k") }, nil, "", ErrNotFound},
+		{"get arity", []string{"ok"}, func() (interface{}, error) { return db.Get("k") }, nil, "", &protocolError},
+		{"del", []string{"ok", "1"}, func() (interface{}, error) { return db.Del("k") }, []string{"del", "k"}, true, nil},
+		{"del error", []string{"error"}, func() (interface{}, error) { return db.Del("k") }, nil, false, ErrServerError},
+		{"exists", []string{"ok", "0"}, func() (interface{}, error) { return db.Exists("k") }, nil, false, nil},
+		{"exists arity", []string{"ok"}, func() (interface{}, error) { return db.Exists("k") }, nil, false, &protocolError},
+		{"exists invalid", []string{"ok", "yes"}, func() (interface{}, error) { return db.Exists("k") }, nil, false, &protocolError},
+		{"keys", []string{"ok", "a", "b"}, func() (interface{}, error) { return db.Keys("", "", 10) }, []string{"keys", "", "", "10"}, []string{"a", "b"}, nil},
+		{"keys empty", []string{"ok"}, func() (interface{}, error) { return db.Keys("", "", 10) }, nil, []string{}, nil},
+		{"scan", []string{"ok", "", "v0", "a", "v1"}, func() (interface{}, error) { return db.Scan("", "", 10) }, nil, Pairs{{"", "v0"}, {"a", "v1"}}, nil},
+		{"scan odd", []string{"ok", "a"}, func() (interface{}, error) { return db.Scan("", "", 10) }, nil, Pairs(nil), &protocolError},
+		{"incr", []string{"ok", "12"}, func() (interface{}, error) { return db.Incr("k", 2) }, []string{"incr", "k", "2"}, int64(12), nil},
+		{"incr invalid", []string{"ok", "x"}, func() (interface{}, error) { return db.Incr("k", 2) }, nil, int64(0), &protocolError},
+		{"multi_set", []string{"ok", "2"}, func() (interface{}, error) { return db.MultiSet([]string{"a", "1", "b", "2"}) }, []string{"multi_set", "a", "1", "b", "2"}, true, nil},
+		{"multi_set error", []string{"error"}, func() (interface{}, error) { return db.MultiSet([]string{"a", "1"}) }, nil, false, ErrServerError},
+		{"multi_del error", []string{"client_error"}, func() (interface{}, error) { return db.MultiDel([]string{"a"}) }, nil, false, ErrClientError},
+		{"zset", []string{"ok", "1"}, func() (interface{}, error) { return nil, db.ZSet("z", "k", 3) }, []string{"zset", "z", "k", "3"}, nil, nil},
+		{"zset error", []string{"error"}, func() (interface{}, error) { return nil, db.ZSet("z", "k", 3) }, nil, nil, ErrServerError},
+		{"zdel missing", []string{"ok", "0"}, func() (interface{}, error) { return db.ZDel("z", "k") }, nil, false, nil},
+		{"zscan", []string{"ok", "a", "1", "b", "2"}, func() (interface{}, error) { return db.ZScan("z", "", 0, 9, 10) }, nil, ZPairs{{"a", 1}, {"b", 2}}, nil},
+		{"zscan invalid", []string{"ok", "a", "x"}, func() (interface{}, error) { return db.ZScan("z", "", 0, 9, 10) }, nil, ZPairs(nil), &protocolError},
+		{"zcount", []string{"ok", "5"}, func() (interface{}, error) { return db.ZCount("z", 0, 9) }, nil, 5, nil},
+		{"multi_zset error", []string{"error"}, func() (interface{}, error) { return nil, db.MultiZset("z", map[string]int64{"a": 1}) }, []string{"multi_zset", "z", "a", "1"}, nil, ErrServerError},
+		{"hset new", []string{"ok", "1"}, func() (interface{}, error) { return db.HSet("h", "k", "v") }, []string{"hset", "h", "k", "v"}, true, nil},
+		{"hset update", []string{"ok", "0"}, func() (interface{}, error) { return db.HSet("h", "k", "v") }, nil, false, nil},
+		{"hset error", []string{"error"}, func() (interface{}, error) { return db.HSet("h", "k", "v") }, nil, false, ErrServerError},
+		{"hdel", []string{"ok", "1"}, func() (interface{}, error) { return db.HDel("h", "k") }, nil, true, nil},
+		{"hdel error", []string{"client_error"}, func() (interface{}, error) { return db.HDel("h", "k") }, nil, false, ErrClientError},
+		{"hgetall", []string{"ok", "a", "1"}, func() (interface{}, error) { return db.HGetAll("h") }, nil, map[string]string{"a": "1"}, nil},
+		{"hsize", []string{"ok", "3"}, func() (interface{}, error) { return db.HSize("h") }, nil, int64(3), nil},
+		{"hclear", []string{"ok", "3"}, func() (interface{}, error) { return db.HClear("h") }, nil, true, nil},
+		{"qpush_back", []string{"ok", "4"}, func() (interface{}, error) { return db.QPushBack("q", "v") }, []string{"qpush_back", "q", "v"}, int64(4), nil},
+		{"qpop_front empty", []string{"not_found"}, func() (interface{}, error) { return db.QPopFront("q") }, nil, "", ErrNotFound},
+		{"qclear error", []string{"error"}, func() (interface{}, error) { return db.QClear("q") }, nil, false, ErrServerError},
+		{"qslice", []string{"ok", "a"}, func() (interface{}, error) { return db.QSlice("q", 0, -1) }, []string{"qslice", "q", "0", "-1"}, []string{"a"}, nil},
+	}
+	for _, tt := range tests {
+		lock.Lock()
+		reply = tt.reply
+		lock.Unlock()
+		v, err := tt.call()
+		if tt.want != nil {
+			assert.Equal(t, tt.want, v, tt.name)
+		}
+		switch e := tt.err.(type) {
+		case nil:
+			assert.Nil(t, err, tt.name)
+		case error:
+			assert.True(t, errors.Is(err, e), tt.name, err)
+		default:
+			assert.True(t, errors.As(err, e), tt.name, err)
+		}
+		if tt.req != nil {
+			lock.Lock()
+			assert.Equal(t, tt.req, got, tt.name)
+			lock.Unlock()
+		}
+	}
+}
+
+func TestDecodeBroken(t *testing.T) {
+	host, port := silentServer(t)
+	db, err := Connect(host, port, conn_timeout, read_timeout, write_timeout)
+	if err != nil {
+		t.Fatalf("connect to server failed: %v", err)
+	}
+	db.Close()
+	//a broken connection yields its error, never a panic or a nil error
+	assert.NotNil(t, db.ZSet("z", "k", 1), "zset")
+	_, err = db.QClear("q")
+	assert.NotNil(t, err, "qclear")
+	_, err = db.HSet("h", "k", "v")
+	assert.NotNil(t, err, "hset")
+	assert.NotNil(t, db.MultiZset("z", map[string]int64{"a": 1}), "multi_zset")
+}
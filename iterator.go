@@ -0,0 +1,270 @@
+package ssdb
+
+//number of items fetched per round trip when BatchSize is not set
+const default_iterator_batch = 100
+
+//The iterators below page through a range command, starting each page
+//after the last item of the previous one. They run on the connection of
+//the client that made them, WithContext included, and are not safe for
+//concurrent use. Items changed while iterating may or may not be seen.
+//
+//	it := db.ScanIterator("", "")
+//	for it.Next() {
+//		fmt.Println(it.Key(), it.Value())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+
+//pager fetches the pages of a range command for the iterators
+type pager[T any] struct {
+	//max number of items fetched per round trip, 0 means default_iterator_batch.
+	//Set it before the first call to Next.
+	BatchSize int
+	//iterates in descending order, set it before the first call to Next
+	Reverse bool
+
+	//fetches up to limit items following last, nil before the first page
+	fetch   func(last *T, limit int, reverse bool) ([]T, error)
+	page    []T
+	cur     T
+	started bool
+	done    bool
+	err     error
+}
+
+//Next advances to the next item, fetching a page when needed. It returns
+//false at the end of the range or on an error, see Err.
+func (p *pager[T]) Next() bool {
+	for len(p.page) == 0 {
+		if p.done || p.err != nil {
+			return false
+		}
+		limit := p.BatchSize
+		if limit <= 0 {
+			limit = default_iterator_batch
+		}
+		var last *T
+		if p.started {
+			last = &p.cur
+		}
+		page, err := p.fetch(last, limit, p.Reverse)
+		if err != nil {
+			p.err = err
+			return false
+		}
+		//a short page is the last one
+		p.done = len(page) < limit
+		p.page = page
+	}
+	p.cur = p.page[0]
+	p.page = p.page[1:]
+	p.started = true
+	return true
+}
+
+//Err returns the error that stopped Next, nil at the end of the range
+func (p *pager[T]) Err() error {
+	return p.err
+}
+
+//ScanIterator iterates over the keys and values of scan,
+//key_start<key<=key_end, or of rscan when Reverse is set,
+//key_end<=key<key_start. An empty key_end leaves the range open.
+type ScanIterator struct {
+	pager[Pair]
+}
+
+func (db *SSDB) ScanIterator(key_start, key_end string) *ScanIterator {
+	it := &ScanIterator{}
+	it.fetch = func(last *Pair, limit int, reverse bool) ([]Pair, error) {
+		start := key_start
+		if last != nil {
+			start = last.Key
+		}
+		if reverse {
+			return db.RScan(start, key_end, limit)
+		}
+		return db.Scan(start, key_end, limit)
+	}
+	return it
+}
+
+func (it *ScanIterator) Key() string {
+	return it.cur.Key
+}
+
+func (it *ScanIterator) Value() string {
+	return it.cur.Value
+}
+
+//HScanIterator iterates over the fields of hash name like ScanIterator,
+//with hscan or hrscan
+type HScanIterator struct {
+	pager[Pair]
+}
+
+func (db *SSDB) HScanIterator(name, key_start, key_end string) *HScanIterator {
+	it := &HScanIterator{}
+	it.fetch = func(last *Pair, limit int, reverse bool) ([]Pair, error) {
+		start := key_start
+		if last != nil {
+			start = last.Key
+		}
+		if reverse {
+			return db.HRscan(name, start, key_end, limit)
+		}
+		return db.HScan(name, start, key_end, limit)
+	}
+	return it
+}
+
+func (it *HScanIterator) Key() string {
+	return it.cur.Key
+}
+
+func (it *HScanIterator) Value() string {
+	return it.cur.Value
+}
+
+//ZScanIterator iterates over the keys of sorted set name by ascending
+//score, score_start<=score<=score_end, or by descending score when
+//Reverse is set, score_start>=score>=score_end. Keys of equal score come
+//in key order.
+type ZScanIterator struct {
+	pager[ZPair]
+}
+
+func (db *SSDB) ZScanIterator(name string, score_start, score_end int64) *ZScanIterator {
+	it := &ZScanIterator{}
+	it.fetch = func(last *ZPair, limit int, reverse bool) ([]ZPair, error) {
+		key, score := "", score_start
+		if last != nil {
+			key, score = last.Key, last.Score
+		}
+		if reverse {
+			return db.ZRscan(name, key, score, score_end, limit)
+		}
+		return db.ZScan(name, key, score, score_end, limit)
+	}
+	return it
+}
+
+func (it *ZScanIterator) Key() string {
+	return it.cur.Key
+}
+
+func (it *ZScanIterator) Score() int64 {
+	return it.cur.Score
+}
+
+type qitem struct {
+	index int64
+	value string
+}
+
+//QRangeIterator iterates over the items of queue name from the front, or
+//from the back when Reverse is set. Pages are fetched by index, so items
+//pushed or popped at the end iteration starts from shift the items seen.
+type QRangeIterator struct {
+	pager[qitem]
+}
+
+func (db *SSDB) QRangeIterator(name string) *QRangeIterator {
+	it := &QRangeIterator{}
+	//index of the next item going forward, of the item after it going back
+	var next int64
+	it.fetch = func(last *qitem, limit int, reverse bool) ([]qitem, error) {
+		begin := next
+		if reverse {
+			if last == nil {
+				size, err := db.QSize(name)
+				if err != nil {
+					return nil, err
+				}
+				next = size
+			}
+			if next <= 0 {
+				return nil, nil
+			}
+			begin = next - int64(limit)
+			if begin < 0 {
+				begin = 0
+			}
+			limit = int(next - begin)
+		}
+		items, err := db.QRange(name, int(begin), limit)
+		if err != nil {
+			return nil, err
+		}
+		page := make([]qitem, len(items))
+		for i, v := range items {
+			page[i] = qitem{index: begin + int64(i), value: v}
+		}
+		if reverse {
+			for i, j := 0, len(page)-1; i < j; i, j = i+1, j-1 {
+				page[i], page[j] = page[j], page[i]
+			}
+			next = begin
+		} else {
+			next += int64(len(page))
+		}
+		return page, nil
+	}
+	return it
+}
+
+//Index returns the index of the item from the front of the queue
+func (it *QRangeIterator) Index() int64 {
+	return it.cur.index
+}
+
+func (it *QRangeIterator) Value() string {
+	return it.cur.value
+}
+
+//ListIterator iterates over the names of hashes, sorted sets or queues,
+//name_start<name<=name_end, or name_end<=name<name_start when Reverse is
+//set. An empty name_end leaves the range open.
+type ListIterator struct {
+	pager[string]
+}
+
+//list and rlist page through the names
+func (db *SSDB) listIterator(name_start string, list, rlist func(name_start string, limit int) ([]string, error)) *ListIterator {
+	it := &ListIterator{}
+	it.fetch = func(last *string, limit int, reverse bool) ([]string, error) {
+		start := name_start
+		if last != nil {
+			start = *last
+		}
+		if reverse {
+			return rlist(start, limit)
+		}
+		return list(start, limit)
+	}
+	return it
+}
+
+func (db *SSDB) HListIterator(name_start, name_end string) *ListIterator {
+	return db.listIterator(name_start,
+		func(start string, limit int) ([]string, error) { return db.HList(start, name_end, limit) },
+		func(start string, limit int) ([]string, error) { return db.HRlist(start, name_end, limit) })
+}
+
+func (db *SSDB) ZListIterator(name_start, name_end string) *ListIterator {
+	return db.listIterator(name_start,
+		func(start string, limit int) ([]string, error) { return db.ZList(start, name_end, limit) },
+		func(start string, limit int) ([]string, error) { return db.ZRlist(start, name_end, limit) })
+}
+
+func (db *SSDB) QListIterator(name_start, name_end string) *ListIterator {
+	return db.listIterator(name_start,
+		func(start string, limit int) ([]string, error) { return db.QList(start, name_end, limit) },
+		func(start string, limit int) ([]string, error) { return db.QRlist(start, name_end, limit) })
+}
+
+//Name returns the current name
+func (it *ListIterator) Name() string {
+	return it.cur
+}
@@ -0,0 +1,72 @@
+//go:build go1.23
+
+package ssdb
+
+import "iter"
+
+//The adapters below turn an iterator into a sequence for range over func.
+//A sequence stops early on an error, check Err of the iterator after the
+//loop. Each one continues from the current position of its iterator.
+//
+//	it := db.ScanIterator("", "")
+//	for key, value := range it.All() {
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+
+//All yields the remaining keys and values
+func (it *ScanIterator) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for it.Next() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+//All yields the remaining fields and values
+func (it *HScanIterator) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for it.Next() {
+			if !yield(it.Key(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+//All yields the remaining keys and scores
+func (it *ZScanIterator) All() iter.Seq2[string, int64] {
+	return func(yield func(string, int64) bool) {
+		for it.Next() {
+			if !yield(it.Key(), it.Score()) {
+				return
+			}
+		}
+	}
+}
+
+//All yields the remaining indexes and items
+func (it *QRangeIterator) All() iter.Seq2[int64, string] {
+	return func(yield func(int64, string) bool) {
+		for it.Next() {
+			if !yield(it.Index(), it.Value()) {
+				return
+			}
+		}
+	}
+}
+
+//All yields the remaining names
+func (it *ListIterator) All() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for it.Next() {
+			if !yield(it.Name()) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+//go:build go1.23
+
+package ssdb
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIteratorSeq(t *testing.T) {
+	db := testDB(t)
+	for i := 0; i < 7; i++ {
+		db.Set(fmt.Sprintf("k%d", i), fmt.Sprint(i))
+		db.HSet("h", fmt.Sprintf("f%d", i), fmt.Sprint(i))
+		db.ZSet("z", fmt.Sprintf("m%d", i), int64(i))
+	}
+	db.QPushBack("q", "a", "b", "c")
+
+	it := db.ScanIterator("", "")
+	it.BatchSize = 3
+	m := map[string]string{}
+	for k, v := range it.All() {
+		m[k] = v
+	}
+	assert.Nil(t, it.Err())
+	assert.Equal(t, 7, len(m))
+	assert.Equal(t, "6", m["k6"])
+
+	hit := db.HScanIterator("h", "", "")
+	hit.BatchSize = 2
+	n := 0
+	for k := range hit.All() {
+		n++
+		if k == "f2" {
+			break
+		}
+	}
+	assert.Equal(t, 3, n, "break stops the sequence")
+	for k := range hit.All() {
+		assert.Equal(t, "f3", k, "a new sequence continues the iterator")
+		break
+	}
+
+	zit := db.ZScanIterator("z", 0, math.MaxInt64)
+	var sum int64
+	for _, score := range zit.All() {
+		sum += score
+	}
+	assert.Equal(t, int64(21), sum)
+
+	var items []string
+	for i, v := range db.QRangeIterator("q").All() {
+		items = append(items, fmt.Sprint(i)+v)
+	}
+	assert.Equal(t, []string{"0a", "1b", "2c"}, items)
+
+	var names []string
+	for name := range db.HListIterator("", "").All() {
+		names = append(names, name)
+	}
+	assert.Equal(t, []string{"h"}, names)
+}
@@ -0,0 +1,186 @@
+package ssdb
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanIterator(t *testing.T) {
+	db := testDB(t)
+	var keys []string
+	for i := 0; i < 25; i++ {
+		key := fmt.Sprintf("k%02d", i)
+		keys = append(keys, key)
+		db.Set(key, "v"+key)
+	}
+
+	for _, batch := range []int{0, 1, 5, 7, 100} {
+		it := db.ScanIterator("", "")
+		it.BatchSize = batch
+		var got []string
+		for it.Next() {
+			assert.Equal(t, "v"+it.Key(), it.Value())
+			got = append(got, it.Key())
+		}
+		assert.Nil(t, it.Err())
+		assert.Equal(t, keys, got, fmt.Sprintf("batch %d", batch))
+		assert.False(t, it.Next(), "stays at the end")
+	}
+
+	it := db.ScanIterator("k04", "k10")
+	it.BatchSize = 4
+	var got []string
+	for it.Next() {
+		got = append(got, it.Key())
+	}
+	assert.Equal(t, keys[5:11], got, "k04<key<=k10")
+
+	it = db.ScanIterator("k10", "k04")
+	it.Reverse = true
+	it.BatchSize = 4
+	got = nil
+	for it.Next() {
+		got = append(got, it.Key())
+	}
+	assert.Equal(t, []string{"k09", "k08", "k07", "k06", "k05", "k04"}, got, "k04<=key<k10 backwards")
+}
+
+func TestHScanIterator(t *testing.T) {
+	db := testDB(t)
+	for i := 0; i < 10; i++ {
+		db.HSet("h", fmt.Sprintf("f%d", i), fmt.Sprint(i))
+	}
+	it := db.HScanIterator("h", "", "")
+	it.BatchSize = 3
+	it.Reverse = true
+	var got []string
+	for it.Next() {
+		got = append(got, it.Key()+"="+it.Value())
+	}
+	assert.Nil(t, it.Err())
+	assert.Equal(t, 10, len(got))
+	assert.Equal(t, "f9=9", got[0])
+	assert.Equal(t, "f0=0", got[9])
+}
+
+func TestZScanIterator(t *testing.T) {
+	db := testDB(t)
+	//equal scores across page boundaries
+	for i := 0; i < 10; i++ {
+		db.ZSet("z", fmt.Sprintf("a%d", i), 1)
+	}
+	db.ZSet("z", "low", -5)
+	db.ZSet("z", "high", 100)
+
+	it := db.ZScanIterator("z", math.MinInt64, math.MaxInt64)
+	it.BatchSize = 3
+	var got ZPairs
+	for it.Next() {
+		got = append(got, ZPair{Key: it.Key(), Score: it.Score()})
+	}
+	assert.Nil(t, it.Err())
+	assert.Equal(t, 12, len(got))
+	assert.Equal(t, ZPair{"low", -5}, got[0])
+	assert.Equal(t, ZPair{"a0", 1}, got[1])
+	assert.Equal(t, ZPair{"a9", 1}, got[10])
+	assert.Equal(t, ZPair{"high", 100}, got[11])
+
+	it = db.ZScanIterator("z", 1, -5)
+	it.Reverse = true
+	it.BatchSize = 4
+	var keys []string
+	for it.Next() {
+		keys = append(keys, it.Key())
+	}
+	assert.Equal(t, []string{"a9", "a8", "a7", "a6", "a5", "a4", "a3", "a2", "a1", "a0", "low"}, keys, "1>=score>=-5")
+}
+
+func TestQRangeIterator(t *testing.T) {
+	db := testDB(t)
+	var items []string
+	for i := 0; i < 10; i++ {
+		items = append(items, fmt.Sprint(i))
+	}
+	db.QPushBack("q", items...)
+
+	for _, reverse := range []bool{false, true} {
+		it := db.QRangeIterator("q")
+		it.BatchSize = 3
+		it.Reverse = reverse
+		n := 0
+		for it.Next() {
+			want := int64(n)
+			if reverse {
+				want = int64(len(items) - 1 - n)
+			}
+			assert.Equal(t, want, it.Index())
+			assert.Equal(t, items[want], it.Value())
+			n++
+		}
+		assert.Nil(t, it.Err())
+		assert.Equal(t, len(items), n, fmt.Sprintf("reverse %v", reverse))
+	}
+
+	it := db.QRangeIterator("empty")
+	it.Reverse = true
+	assert.False(t, it.Next(), "empty queue")
+	assert.Nil(t, it.Err())
+}
+
+func TestListIterators(t *testing.T) {
+	db := testDB(t)
+	var names []string
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("n%d", i)
+		names = append(names, name)
+		db.HSet(name, "f", "v")
+		db.ZSet(name, "k", 1)
+		db.QPushBack(name, "v")
+	}
+	iterators := map[string]func() *ListIterator{
+		"hlist": func() *ListIterator { return db.HListIterator("", "") },
+		"zlist": func() *ListIterator { return db.ZListIterator("", "") },
+		"qlist": func() *ListIterator { return db.QListIterator("", "") },
+	}
+	for cmd, newIterator := range iterators {
+		it := newIterator()
+		it.BatchSize = 2
+		var got []string
+		for it.Next() {
+			got = append(got, it.Name())
+		}
+		assert.Nil(t, it.Err())
+		assert.Equal(t, names, got, cmd)
+
+		it = newIterator()
+		it.BatchSize = 2
+		it.Reverse = true
+		got = nil
+		for it.Next() {
+			got = append(got, it.Name())
+		}
+		assert.Equal(t, []string{"n4", "n3", "n2", "n1", "n0"}, got, cmd+" reversed")
+	}
+}
+
+func TestIteratorError(t *testing.T) {
+	srv := testServer(t)
+	db, err := Dial(srv.Addr(), WithReconnect(false))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		db.Set(fmt.Sprint(i), "v")
+	}
+	it := db.ScanIterator("", "")
+	it.BatchSize = 2
+	assert.True(t, it.Next())
+	assert.True(t, it.Next())
+	db.Close()
+	assert.False(t, it.Next(), "next page fails")
+	assert.ErrorIs(t, it.Err(), ErrBroken)
+	assert.False(t, it.Next(), "stays failed")
+}
@@ -0,0 +1,264 @@
+package ssdb
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+const (
+	//size of the read and write buffers when no option sets them
+	default_buffer_size = 4096
+	//wait before the second reconnect attempt, doubled after each failure
+	default_min_backoff = 100 * time.Millisecond
+	default_max_backoff = 10 * time.Second
+)
+
+//Option configures a client created by Dial
+type Option func(*options)
+
+//Logger receives the messages of a client, *log.Logger implements it
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type options struct {
+	conntimeout  time.Duration
+	readtimeout  time.Duration
+	writetimeout time.Duration
+	password     string
+	tlsconfig    *tls.Config
+	keepalive    time.Duration
+	readbuffer   int
+	writebuffer  int
+	dialer       func(ctx context.Context, network, addr string) (net.Conn, error)
+	logger       Logger
+	reconnect    bool
+	minbackoff   time.Duration
+	maxbackoff   time.Duration
+	retry        RetryPolicy
+	maxblock     int
+	maxresponse  int
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		readbuffer:  default_buffer_size,
+		writebuffer: default_buffer_size,
+		reconnect:   true,
+		minbackoff:  default_min_backoff,
+		maxbackoff:  default_max_backoff,
+		maxblock:    default_max_block_size,
+		maxresponse: default_max_response_size,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+//wait before the next dial after n consecutive failures
+func (o *options) backoff(n int) time.Duration {
+	d := o.minbackoff
+	for i := 1; i < n && d < o.maxbackoff; i++ {
+		d *= 2
+	}
+	if d > o.maxbackoff {
+		d = o.maxbackoff
+	}
+	return d
+}
+
+func (o *options) logf(format string, v ...interface{}) {
+	if o.logger != nil {
+		o.logger.Printf(format, v...)
+	}
+}
+
+//max time to establish a connection, including TLS handshake and auth,
+//0 means no limit
+func WithConnTimeout(d time.Duration) Option {
+	return func(o *options) { o.conntimeout = d }
+}
+
+//max time to wait for a reply, 0 means no limit
+func WithReadTimeout(d time.Duration) Option {
+	return func(o *options) { o.readtimeout = d }
+}
+
+//max time to write a request, 0 means no limit
+func WithWriteTimeout(d time.Duration) Option {
+	return func(o *options) { o.writetimeout = d }
+}
+
+//sent with auth on every new connection
+func WithPassword(password string) Option {
+	return func(o *options) { o.password = password }
+}
+
+//wraps connections in TLS, an empty ServerName is taken from the address
+func WithTLS(config *tls.Config) Option {
+	return func(o *options) { o.tlsconfig = config }
+}
+
+//interval of TCP keepalive probes, 0 means the net.Dialer default and a
+//negative value turns them off; ignored with WithDialer
+func WithKeepAlive(d time.Duration) Option {
+	return func(o *options) { o.keepalive = d }
+}
+
+func WithReadBufferSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.readbuffer = n
+		}
+	}
+}
+
+func WithWriteBufferSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.writebuffer = n
+		}
+	}
+}
+
+//largest block a reply may carry, a larger one fails the command with a
+//*ProtocolError and breaks the connection
+func WithMaxBlockSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxblock = n
+		}
+	}
+}
+
+//largest reply, all blocks together, see WithMaxBlockSize
+func WithMaxResponseSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.maxresponse = n
+		}
+	}
+}
+
+//replaces net.Dialer, e.g. to go through a proxy
+func WithDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(o *options) { o.dialer = dial }
+}
+
+func WithLogger(logger Logger) Option {
+	return func(o *options) { o.logger = logger }
+}
+
+//whether a command on a broken connection first reopens it, on by
+//default; when off commands return ErrBroken until Connect is called
+func WithReconnect(enabled bool) Option {
+	return func(o *options) { o.reconnect = enabled }
+}
+
+//after a failed reconnect, commands fail without dialing for min, then
+//twice as long after each further failure, up to max
+func WithReconnectBackoff(min, max time.Duration) Option {
+	return func(o *options) {
+		if min > 0 {
+			o.minbackoff = min
+		}
+		if max >= o.minbackoff {
+			o.maxbackoff = max
+		}
+	}
+}
+
+//sends a read command again, once, when the connection broke under it.
+//It replaces the retry policy, see WithRetryPolicy.
+func WithReadRetry(enabled bool) Option {
+	return func(o *options) {
+		o.retry = RetryPolicy{}
+		if enabled {
+			o.retry = RetryPolicy{MaxAttempts: 2, Kinds: ReadCommand}
+		}
+	}
+}
+
+//retries commands failing with a transient error, only those of the
+//policy's Kinds. A broken connection is reopened before each retry
+//unless WithReconnect(false) was given.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) { o.retry = policy }
+}
+
+//Dial connects to the server at addr, a "host:port" string. On error it
+//still returns the client, closed: Close is safe and commands return
+//ErrBroken until Connect succeeds.
+func Dial(addr string, opts ...Option) (*SSDB, error) {
+	db := NewClient(addr, opts...)
+	if err := db.Connect(); err != nil {
+		db.Close()
+		return db, err
+	}
+	return db, nil
+}
+
+//opens a connection and authenticates it
+func connect(addr string, o *options) (Conn, error) {
+	ctx := context.Background()
+	if o.conntimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.conntimeout)
+		defer cancel()
+	}
+	dial := o.dialer
+	if dial == nil {
+		d := &net.Dialer{KeepAlive: o.keepalive}
+		dial = d.DialContext
+	}
+	connection, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if o.tlsconfig != nil {
+		config := o.tlsconfig
+		if config.ServerName == "" {
+			config = config.Clone()
+			config.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(connection, config)
+		if err = tc.HandshakeContext(ctx); err != nil {
+			connection.Close()
+			return nil, err
+		}
+		connection = tc
+	}
+	c := &conn{client: connection, readtimeout: o.readtimeout, writetimeout: o.writetimeout,
+		maxblock: o.maxblock, maxresponse: o.maxresponse}
+	c.writer = bufio.NewWriterSize(connection, o.writebuffer)
+	c.reader = bufio.NewReaderSize(connection, o.readbuffer)
+	if o.password != "" {
+		//auth is bounded by the connect timeout rather than the read timeout
+		if deadline, ok := ctx.Deadline(); ok {
+			c.ctxdeadline = deadline
+		}
+		err = auth(c, addr, o.password)
+		c.ctxdeadline = time.Time{}
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+//authenticates a new connection, a rejected password gives an *AuthError
+func auth(c Conn, addr, password string) error {
+	rsp, err := c.Do("auth", []interface{}{password})
+	if err != nil {
+		return err
+	}
+	if err = statusError("auth", rsp); err != nil {
+		return &AuthError{Addr: addr, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package ssdb
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/jiecao-fm/ssdb/ssdbtest"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type logRecorder struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (l *logRecorder) Printf(format string, v ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestDial(t *testing.T) {
+	srv := testServer(t)
+	srv.RequirePass("secret")
+	var dials atomic.Int32
+	dialer := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials.Add(1)
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+	db, err := Dial(srv.Addr(), WithPassword("secret"), WithDialer(dialer),
+		WithReadTimeout(time.Second), WithReadBufferSize(16), WithWriteBufferSize(16))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer db.Close()
+	assert.Equal(t, int32(1), dials.Load(), "custom dialer used")
+
+	long := strings.Repeat("x", 1000)
+	assert.Nil(t, db.Set("k", long))
+	v, err := db.Get("k")
+	assert.Nil(t, err)
+	assert.Equal(t, long, v, "values larger than the buffers")
+
+	failed, err := Dial(srv.Addr(), WithPassword("wrong"))
+	var authErr *AuthError
+	assert.ErrorAs(t, err, &authErr, "dial with a wrong password")
+	if assert.NotNil(t, failed, "failed dial returned no client") {
+		defer failed.Close()
+		assert.ErrorIs(t, failed.Ping(), ErrBroken, "failed dial left the client open")
+		assert.ErrorIs(t, failed.Err(), ErrBroken)
+	}
+}
+
+func TestNewConnectsLazily(t *testing.T) {
+	srv := testServer(t)
+	var dials atomic.Int32
+	dialer := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials.Add(1)
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+	db := NewClient(srv.Addr(), WithDialer(dialer))
+	defer db.Close()
+	assert.Nil(t, db.Err())
+	assert.Equal(t, int32(0), dials.Load(), "NewClient dialed")
+	assert.Nil(t, db.Set("k", "v"), "first command connects")
+	assert.Nil(t, db.Ping())
+	assert.Equal(t, int32(1), dials.Load(), "connection reused")
+
+	unused := NewClient(srv.Addr(), WithDialer(dialer))
+	unused.Close()
+	assert.ErrorIs(t, unused.Ping(), ErrBroken, "closed before the first command")
+	assert.ErrorIs(t, unused.Err(), ErrBroken)
+	assert.Equal(t, int32(1), dials.Load(), "closed client dialed")
+	assert.Nil(t, unused.Connect(), "Connect reopens a closed client")
+	assert.Nil(t, unused.Ping())
+	unused.Close()
+
+	db, err := New(srv.Host(), srv.Port(), conn_timeout, read_timeout, write_timeout)
+	assert.Nil(t, err)
+	v, err := db.Get("k")
+	assert.Nil(t, err, "client from New is usable")
+	assert.Equal(t, "v", v)
+	db.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	logger := &logRecorder{}
+	db = NewClient(addr, WithLogger(logger))
+	assert.NotNil(t, db.Ping(), "server down")
+	assert.Equal(t, 1, len(logger.lines), "connect failure logged")
+	assert.True(t, strings.Contains(logger.lines[0], addr), "logged address")
+}
+
+//a self-signed certificate for 127.0.0.1
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestDialTLS(t *testing.T) {
+	cert, roots := testCertificate(t)
+	srv, err := ssdbtest.NewTLSServer(&tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("start server failed: %v", err)
+	}
+	defer srv.Close()
+
+	db, err := Dial(srv.Addr(), WithTLS(&tls.Config{RootCAs: roots}), WithConnTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer db.Close()
+	assert.Nil(t, db.Set("k", "v"))
+	v, _ := db.Get("k")
+	assert.Equal(t, "v", v, "command over TLS")
+
+	_, err = Dial(srv.Addr(), WithTLS(&tls.Config{}), WithConnTimeout(time.Second))
+	assert.NotNil(t, err, "untrusted certificate")
+
+	pool, err := NewPool(PoolConfig{Host: srv.Host(), Port: srv.Port(), Initial_conn_count: 1, Max_conn_count: 1,
+		DialOptions: []Option{WithTLS(&tls.Config{RootCAs: roots})}})
+	if err != nil {
+		t.Fatalf("new pool failed: %v", err)
+	}
+	defer pool.Close()
+	pdb, err := pool.GetDB()
+	assert.Nil(t, err)
+	v, _ = pdb.Get("k")
+	assert.Equal(t, "v", v, "pool over TLS")
+	pool.ReturnDB(pdb)
+}
@@ -0,0 +1,59 @@
+package ssdb
+
+//Range commands return pairs in the order of the server reply, so the
+//last key of a page can start the next one. Map is there for callers
+//that do not care about the order.
+
+//Pair is a key with its value
+type Pair struct {
+	Key   string
+	Value string
+}
+
+//Pairs keeps the order of the server reply
+type Pairs []Pair
+
+//Keys returns the keys in order
+func (ps Pairs) Keys() []string {
+	keys := make([]string, len(ps))
+	for i, p := range ps {
+		keys[i] = p.Key
+	}
+	return keys
+}
+
+//Map returns the pairs as a map, losing their order
+func (ps Pairs) Map() map[string]string {
+	m := make(map[string]string, len(ps))
+	for _, p := range ps {
+		m[p.Key] = p.Value
+	}
+	return m
+}
+
+//ZPair is a key of a sorted set with its score
+type ZPair struct {
+	Key   string
+	Score int64
+}
+
+//ZPairs keeps the order of the server reply
+type ZPairs []ZPair
+
+//Keys returns the keys in order
+func (zs ZPairs) Keys() []string {
+	keys := make([]string, len(zs))
+	for i, z := range zs {
+		keys[i] = z.Key
+	}
+	return keys
+}
+
+//Map returns the pairs as a map, losing their order
+func (zs ZPairs) Map() map[string]int64 {
+	m := make(map[string]int64, len(zs))
+	for _, z := range zs {
+		m[z.Key] = z.Score
+	}
+	return m
+}
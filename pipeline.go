@@ -0,0 +1,492 @@
+package ssdb
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+//number of commands written per flush when Pipeline.BatchSize is not set
+const default_pipeline_batch = 256
+
+var errNotExecuted = errors.New("ssdb: pipeline not executed")
+
+//Result holds the reply of a command queued in a Pipeline,
+//it is filled in by Pipeline.Exec
+type Result[T any] struct {
+	val T
+	err error
+}
+
+func (r *Result[T]) Val() T {
+	return r.val
+}
+
+func (r *Result[T]) Err() error {
+	return r.err
+}
+
+func (r *Result[T]) Result() (T, error) {
+	return r.val, r.err
+}
+
+//result of a command whose reply carries only a status
+type StatusResult = Result[struct{}]
+
+type pipelineCmd struct {
+	cmd   string
+	args  []interface{}
+	reply func(rsp *Response, err error)
+}
+
+//Pipeline queues commands and sends them to the server in batches,
+//one flush per batch, instead of one round trip per command.
+//A Pipeline is not safe for concurrent use.
+type Pipeline struct {
+	db   *SSDB
+	cmds []pipelineCmd
+	//max number of commands written per flush, 0 means default_pipeline_batch
+	BatchSize int
+}
+
+//Pipeline returns an empty pipeline running on the connection of db
+func (db *SSDB) Pipeline() *Pipeline {
+	return &Pipeline{db: db}
+}
+
+//Len returns the number of queued commands
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+//Discard drops the queued commands
+func (p *Pipeline) Discard() {
+	p.cmds = nil
+}
+
+//returns a result already holding err, nothing is queued
+func failed[T any](err error) *Result[T] {
+	return &Result[T]{err: err}
+}
+
+func queue[T any](p *Pipeline, decode func(*Response) (T, error), cmd string, args ...interface{}) *Result[T] {
+	r := &Result[T]{err: errNotExecuted}
+	p.cmds = append(p.cmds, pipelineCmd{cmd: cmd, args: args, reply: func(rsp *Response, err error) {
+		if err == nil {
+			err = statusError(cmd, rsp)
+		}
+		if err != nil {
+			r.err = err
+			return
+		}
+		r.val, r.err = decode(rsp)
+	}})
+	return r
+}
+
+//Exec sends the queued commands and fills in their results in order.
+//It returns a non-nil error only when the connection failed, in which
+//case every command not answered carries that error too. Errors reported
+//by the server for a single command are available from its result.
+func (p *Pipeline) Exec() error {
+	cmds := p.cmds
+	p.cmds = nil
+	batch := p.BatchSize
+	if batch <= 0 {
+		batch = default_pipeline_batch
+	}
+	for len(cmds) > 0 {
+		n := batch
+		if n > len(cmds) {
+			n = len(cmds)
+		}
+		err := p.exec(cmds[:n])
+		if err != nil {
+			for _, c := range cmds {
+				c.reply(nil, err)
+			}
+			return err
+		}
+		cmds = cmds[n:]
+	}
+	return nil
+}
+
+//sends one batch, replies are delivered as they arrive
+func (p *Pipeline) exec(cmds []pipelineCmd) error {
+	conn, err := p.db.getConn()
+	if err != nil {
+		return err
+	}
+	if conn.Err() != nil {
+		return ErrBroken
+	}
+	run := func() error {
+		sent := make([]int, 0, len(cmds))
+		for i, c := range cmds {
+			if err := conn.Send(c.cmd, c.args); err != nil {
+				if conn.Err() != nil {
+					return err
+				}
+				//the arguments could not be encoded and nothing was
+				//written, only this command fails
+				cmds[i].reply(nil, err)
+				cmds[i].reply = func(*Response, error) {}
+				continue
+			}
+			sent = append(sent, i)
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+		for _, i := range sent {
+			rsp, err := conn.Receive()
+			if err != nil {
+				return err
+			}
+			cmds[i].reply(rsp, nil)
+			cmds[i].reply = func(*Response, error) {}
+		}
+		return nil
+	}
+	if c, ok := conn.(interface {
+		withContext(context.Context, func() error) error
+	}); ok && p.db.ctx != nil {
+		return c.withContext(p.db.ctx, run)
+	}
+	return run()
+}
+
+func (p *Pipeline) Ping() *StatusResult {
+	return queue(p, statusOnly, "ping")
+}
+func (p *Pipeline) Set(key string, value string) *StatusResult {
+	return queue(p, statusOnly, "set", key, value)
+}
+func (p *Pipeline) Get(key string) *Result[string] {
+	return queue(p, StringValue, "get", key)
+}
+func (p *Pipeline) Del(key string) *Result[bool] {
+	return queue(p, BoolValue, "del", key)
+}
+func (p *Pipeline) Exists(key string) *Result[bool] {
+	return queue(p, boolReply, "exists", key)
+}
+func (p *Pipeline) Keys(key_start, key_end string, limit int) *Result[[]string] {
+	return queue(p, StringArray, "keys", key_start, key_end, limit)
+}
+func (p *Pipeline) Scan(key_start, key_end string, limit int) *Result[Pairs] {
+	return queue(p, pairReply, "scan", key_start, key_end, limit)
+}
+func (p *Pipeline) RScan(key_start, key_end string, limit int) *Result[Pairs] {
+	return queue(p, pairReply, "rscan", key_start, key_end, limit)
+}
+func (p *Pipeline) Incr(key string, by int64) *Result[int64] {
+	return queue(p, Int64, "incr", key, by)
+}
+func (p *Pipeline) SetX(key, value string, ttl time.Duration) *StatusResult {
+	secs, err := ttlSeconds(ttl)
+	if err != nil {
+		return failed[struct{}](err)
+	}
+	return queue(p, statusOnly, "setx", key, value, secs)
+}
+func (p *Pipeline) SetNX(key, value string) *Result[bool] {
+	return queue(p, boolReply, "setnx", key, value)
+}
+func (p *Pipeline) GetSet(key, value string) *Result[string] {
+	return queue(p, StringValue, "getset", key, value)
+}
+func (p *Pipeline) Expire(key string, ttl time.Duration) *Result[bool] {
+	secs, err := ttlSeconds(ttl)
+	if err != nil {
+		return failed[bool](err)
+	}
+	return queue(p, boolReply, "expire", key, secs)
+}
+func (p *Pipeline) TTL(key string) *Result[time.Duration] {
+	return queue(p, ttlReply, "ttl", key)
+}
+func (p *Pipeline) SetBit(key string, offset int64, on bool) *Result[bool] {
+	bit := 0
+	if on {
+		bit = 1
+	}
+	return queue(p, boolReply, "setbit", key, offset, bit)
+}
+func (p *Pipeline) GetBit(key string, offset int64) *Result[bool] {
+	return queue(p, boolReply, "getbit", key, offset)
+}
+func (p *Pipeline) BitCount(key string, start, end int64) *Result[int64] {
+	return queue(p, Int64, "bitcount", key, start, end)
+}
+func (p *Pipeline) CountBit(key string, start, size int64) *Result[int64] {
+	return queue(p, Int64, "countbit", key, start, size)
+}
+func (p *Pipeline) Substr(key string, start, size int64) *Result[string] {
+	return queue(p, StringValue, "substr", key, start, size)
+}
+func (p *Pipeline) StrLen(key string) *Result[int64] {
+	return queue(p, Int64, "strlen", key)
+}
+func (p *Pipeline) MultiSet(kvs []string) *Result[bool] {
+	return queue(p, BoolValue, "multi_set", kvs)
+}
+func (p *Pipeline) MultiGet(keys []string) *Result[map[string]string] {
+	return queue(p, StringMap, "multi_get", keys)
+}
+func (p *Pipeline) MultiDel(keys []string) *Result[bool] {
+	return queue(p, BoolValue, "multi_del", keys)
+}
+
+func (p *Pipeline) ZSet(setname, key string, score int64) *StatusResult {
+	return queue(p, statusOnly, "zset", setname, key, score)
+}
+func (p *Pipeline) ZGet(setname, key string) *Result[int64] {
+	return queue(p, Int64, "zget", setname, key)
+}
+func (p *Pipeline) ZIncr(setname, key string, by int64) *Result[int64] {
+	return queue(p, Int64, "zincr", setname, key, by)
+}
+func (p *Pipeline) ZDel(setname, key string) *Result[bool] {
+	return queue(p, boolReply, "zdel", setname, key)
+}
+func (p *Pipeline) ZSize(setname string) *Result[int64] {
+	return queue(p, Int64, "zsize", setname)
+}
+func (p *Pipeline) ZScan(setname, key_start string, score_start, score_end int64, limit int) *Result[ZPairs] {
+	return queue(p, zpairReply, "zscan", setname, key_start, score_start, score_end, limit)
+}
+func (p *Pipeline) ZList(name_start, name_end string, limit int) *Result[[]string] {
+	return queue(p, StringArray, "zlist", name_start, name_end, limit)
+}
+func (p *Pipeline) ZClear(setname string) *StatusResult {
+	return queue(p, statusOnly, "zclear", setname)
+}
+func (p *Pipeline) ZCount(setname string, score_start, score_end int64) *Result[int] {
+	return queue(p, IntValue, "zcount", setname, score_start, score_end)
+}
+func (p *Pipeline) ZExists(setname, key string) *Result[bool] {
+	return queue(p, boolReply, "zexists", setname, key)
+}
+func (p *Pipeline) ZKeys(setname, key_start string, score_start, score_end int64, limit int) *Result[[]string] {
+	return queue(p, StringArray, "zkeys", setname, key_start, score_start, score_end, limit)
+}
+func (p *Pipeline) MultiZGet(setname string, keys []string) *Result[ZPairs] {
+	return queue(p, zpairReply, "multi_zget", setname, keys)
+}
+func (p *Pipeline) MultiZset(setname string, kvs map[string]int64) *StatusResult {
+	return queue(p, statusOnly, "multi_zset", setname, kvs)
+}
+func (p *Pipeline) MultiZDel(setname string, keys []string) *Result[bool] {
+	return queue(p, BoolValue, "multi_zdel", setname, keys)
+}
+func (p *Pipeline) ZRank(setname, key string) *Result[int64] {
+	return queue(p, Int64, "zrank", setname, key)
+}
+func (p *Pipeline) ZRrank(setname, key string) *Result[int64] {
+	return queue(p, Int64, "zrrank", setname, key)
+}
+func (p *Pipeline) ZRange(setname string, offset, limit int) *Result[ZPairs] {
+	return queue(p, zpairReply, "zrange", setname, offset, limit)
+}
+func (p *Pipeline) ZRrange(setname string, offset, limit int) *Result[ZPairs] {
+	return queue(p, zpairReply, "zrrange", setname, offset, limit)
+}
+func (p *Pipeline) ZRscan(setname, key_start string, score_start, score_end int64, limit int) *Result[ZPairs] {
+	return queue(p, zpairReply, "zrscan", setname, key_start, score_start, score_end, limit)
+}
+func (p *Pipeline) ZRlist(name_start, name_end string, limit int) *Result[[]string] {
+	return queue(p, StringArray, "zrlist", name_start, name_end, limit)
+}
+func (p *Pipeline) ZRemRangeByRank(setname string, start, end int64) *Result[int64] {
+	return queue(p, Int64, "zremrangebyrank", setname, start, end)
+}
+func (p *Pipeline) ZRemRangeByScore(setname string, score_start, score_end int64) *Result[int64] {
+	return queue(p, Int64, "zremrangebyscore", setname, score_start, score_end)
+}
+func (p *Pipeline) ZSum(setname string, score_start, score_end int64) *Result[int64] {
+	return queue(p, Int64, "zsum", setname, score_start, score_end)
+}
+func (p *Pipeline) ZAvg(setname string, score_start, score_end int64) *Result[float64] {
+	return queue(p, floatReply, "zavg", setname, score_start, score_end)
+}
+func (p *Pipeline) ZPopFront(setname string, limit int) *Result[ZPairs] {
+	return queue(p, zpairReply, "zpop_front", setname, limit)
+}
+func (p *Pipeline) ZPopBack(setname string, limit int) *Result[ZPairs] {
+	return queue(p, zpairReply, "zpop_back", setname, limit)
+}
+
+func (p *Pipeline) HSet(name, key, value string) *Result[bool] {
+	return queue(p, boolReply, "hset", name, key, value)
+}
+func (p *Pipeline) HGet(name, key string) *Result[string] {
+	return queue(p, StringValue, "hget", name, key)
+}
+func (p *Pipeline) HDel(name, key string) *Result[bool] {
+	return queue(p, boolReply, "hdel", name, key)
+}
+func (p *Pipeline) HIncr(name, key string, by int64) *Result[int64] {
+	return queue(p, Int64, "hincr", name, key, by)
+}
+func (p *Pipeline) HExists(name, key string) *Result[bool] {
+	return queue(p, boolReply, "hexists", name, key)
+}
+func (p *Pipeline) HSize(name string) *Result[int64] {
+	return queue(p, Int64, "hsize", name)
+}
+func (p *Pipeline) HList(name_start, name_end string, limit int) *Result[[]string] {
+	return queue(p, StringArray, "hlist", name_start, name_end, limit)
+}
+func (p *Pipeline) HRlist(name_start, name_end string, limit int) *Result[[]string] {
+	return queue(p, StringArray, "hrlist", name_start, name_end, limit)
+}
+func (p *Pipeline) HKeys(name, key_start, key_end string, limit int) *Result[[]string] {
+	return queue(p, StringArray, "hkeys", name, key_start, key_end, limit)
+}
+func (p *Pipeline) HGetAll(name string) *Result[map[string]string] {
+	return queue(p, StringMap, "hgetall", name)
+}
+func (p *Pipeline) HScan(name, key_start, key_end string, limit int) *Result[Pairs] {
+	return queue(p, pairReply, "hscan", name, key_start, key_end, limit)
+}
+func (p *Pipeline) HRscan(name, key_start, key_end string, limit int) *Result[Pairs] {
+	return queue(p, pairReply, "hrscan", name, key_start, key_end, limit)
+}
+func (p *Pipeline) HClear(name string) *Result[bool] {
+	return queue(p, BoolValue, "hclear", name)
+}
+func (p *Pipeline) MultiHSet(name string, kvs []string) *Result[bool] {
+	return queue(p, BoolValue, "multi_hset", name, kvs)
+}
+func (p *Pipeline) MultiHGet(name string, keys []string) *Result[map[string]string] {
+	return queue(p, StringMap, "multi_hget", name, keys)
+}
+func (p *Pipeline) MultiHDel(name string, keys []string) *Result[bool] {
+	return queue(p, BoolValue, "multi_hdel", name, keys)
+}
+
+func (p *Pipeline) QPushFront(name string, items ...string) *Result[int64] {
+	return queue(p, Int64, "qpush_front", name, items)
+}
+func (p *Pipeline) QPushBack(name string, items ...string) *Result[int64] {
+	return queue(p, Int64, "qpush_back", name, items)
+}
+func (p *Pipeline) QPopFront(name string) *Result[string] {
+	return queue(p, StringValue, "qpop_front", name)
+}
+func (p *Pipeline) QPopBack(name string) *Result[string] {
+	return queue(p, StringValue, "qpop_back", name)
+}
+func (p *Pipeline) QPopFrontN(name string, size int) *Result[[]string] {
+	return queue(p, StringArray, "qpop_front", name, size)
+}
+func (p *Pipeline) QPopBackN(name string, size int) *Result[[]string] {
+	return queue(p, StringArray, "qpop_back", name, size)
+}
+func (p *Pipeline) QTrimFront(name string, size int) *Result[int64] {
+	return queue(p, Int64, "qtrim_front", name, size)
+}
+func (p *Pipeline) QTrimBack(name string, size int) *Result[int64] {
+	return queue(p, Int64, "qtrim_back", name, size)
+}
+func (p *Pipeline) QSet(name string, index int64, value string) *StatusResult {
+	return queue(p, statusOnly, "qset", name, index, value)
+}
+func (p *Pipeline) QRange(name string, offset, limit int) *Result[[]string] {
+	return queue(p, StringArray, "qrange", name, offset, limit)
+}
+func (p *Pipeline) QFix(name string) *StatusResult {
+	return queue(p, statusOnly, "qfix", name)
+}
+func (p *Pipeline) QSize(name string) *Result[int64] {
+	return queue(p, Int64, "qsize", name)
+}
+func (p *Pipeline) QList(name_start, name_end string, limit int) *Result[[]string] {
+	return queue(p, StringArray, "qlist", name_start, name_end, limit)
+}
+func (p *Pipeline) QRlist(name_start, name_end string, limit int) *Result[[]string] {
+	return queue(p, StringArray, "qrlist", name_start, name_end, limit)
+}
+func (p *Pipeline) QClear(name string) *Result[bool] {
+	return queue(p, BoolValue, "qclear", name)
+}
+func (p *Pipeline) QFront(name string) *Result[string] {
+	return queue(p, StringValue, "qfront", name)
+}
+func (p *Pipeline) QBack(name string) *Result[string] {
+	return queue(p, StringValue, "qback", name)
+}
+func (p *Pipeline) QGet(name string, index int64) *Result[string] {
+	return queue(p, StringValue, "qget", name, index)
+}
+func (p *Pipeline) QSlice(name string, begin, end int64) *Result[[]string] {
+	return queue(p, StringArray, "qslice", name, begin, end)
+}
+
+//[]byte values are written by Exec, they must not change until then
+func (p *Pipeline) SetBytes(key string, value []byte) *StatusResult {
+	return queue(p, statusOnly, "set", key, value)
+}
+func (p *Pipeline) GetBytes(key string) *Result[[]byte] {
+	return queue(p, BytesValue, "get", key)
+}
+func (p *Pipeline) SetXBytes(key string, value []byte, ttl time.Duration) *StatusResult {
+	secs, err := ttlSeconds(ttl)
+	if err != nil {
+		return failed[struct{}](err)
+	}
+	return queue(p, statusOnly, "setx", key, value, secs)
+}
+func (p *Pipeline) GetSetBytes(key string, value []byte) *Result[[]byte] {
+	return queue(p, BytesValue, "getset", key, value)
+}
+func (p *Pipeline) MultiSetBytes(kvs map[string][]byte) *StatusResult {
+	return queue(p, statusOnly, "multi_set", kvs)
+}
+func (p *Pipeline) MultiGetBytes(keys []string) *Result[map[string][]byte] {
+	return queue(p, BytesMap, "multi_get", keys)
+}
+func (p *Pipeline) HSetBytes(name, key string, value []byte) *Result[bool] {
+	return queue(p, boolReply, "hset", name, key, value)
+}
+func (p *Pipeline) HGetBytes(name, key string) *Result[[]byte] {
+	return queue(p, BytesValue, "hget", name, key)
+}
+func (p *Pipeline) HGetAllBytes(name string) *Result[map[string][]byte] {
+	return queue(p, BytesMap, "hgetall", name)
+}
+func (p *Pipeline) MultiHSetBytes(name string, kvs map[string][]byte) *StatusResult {
+	return queue(p, statusOnly, "multi_hset", name, kvs)
+}
+func (p *Pipeline) MultiHGetBytes(name string, keys []string) *Result[map[string][]byte] {
+	return queue(p, BytesMap, "multi_hget", name, keys)
+}
+func (p *Pipeline) QPushFrontBytes(name string, items ...[]byte) *Result[int64] {
+	return queue(p, Int64, "qpush_front", name, items)
+}
+func (p *Pipeline) QPushBackBytes(name string, items ...[]byte) *Result[int64] {
+	return queue(p, Int64, "qpush_back", name, items)
+}
+func (p *Pipeline) QPopFrontBytes(name string) *Result[[]byte] {
+	return queue(p, BytesValue, "qpop_front", name)
+}
+func (p *Pipeline) QPopBackBytes(name string) *Result[[]byte] {
+	return queue(p, BytesValue, "qpop_back", name)
+}
+func (p *Pipeline) QFrontBytes(name string) *Result[[]byte] {
+	return queue(p, BytesValue, "qfront", name)
+}
+func (p *Pipeline) QBackBytes(name string) *Result[[]byte] {
+	return queue(p, BytesValue, "qback", name)
+}
+func (p *Pipeline) QGetBytes(name string, index int64) *Result[[]byte] {
+	return queue(p, BytesValue, "qget", name, index)
+}
+func (p *Pipeline) QSetBytes(name string, index int64, value []byte) *StatusResult {
+	return queue(p, statusOnly, "qset", name, index, value)
+}
+func (p *Pipeline) QRangeBytes(name string, offset, limit int) *Result[[][]byte] {
+	return queue(p, BytesArray, "qrange", name, offset, limit)
+}
@@ -0,0 +1,77 @@
+package ssdb
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	var requests atomic.Int32
+	host, port := replyServer(t, func(req []string) []string {
+		requests.Add(1)
+		switch req[0] {
+		case "get":
+			if req[1] == "missing" {
+				return []string{"not_found"}
+			}
+			return []string{"ok", "value_" + req[1]}
+		case "incr":
+			return []string{"ok", req[2]}
+		case "hgetall":
+			return []string{"ok", "k1", "v1", "k2", "v2"}
+		}
+		return []string{"ok"}
+	})
+	db, err := Connect(host, port, conn_timeout, read_timeout, write_timeout)
+	if err != nil {
+		t.Fatalf("connect to server failed: %v", err)
+	}
+	defer db.Close()
+
+	p := db.Pipeline()
+	p.BatchSize = 3
+	set := p.Set("key1", "value1")
+	var gets []*Result[string]
+	for i := 0; i < 5; i++ {
+		gets = append(gets, p.Get(fmt.Sprintf("key%d", i)))
+	}
+	missing := p.Get("missing")
+	incr := p.Incr("counter", 7)
+	all := p.HGetAll("hash1")
+	assert.Equal(t, 9, p.Len(), "commands not queued")
+	assert.Equal(t, errNotExecuted, set.Err(), "result set before exec")
+
+	err = p.Exec()
+	assert.Nil(t, err, "exec failed")
+	assert.Equal(t, int32(9), requests.Load(), "commands not sent")
+	assert.Equal(t, 0, p.Len(), "queue not reset")
+	assert.Nil(t, set.Err(), "set failed")
+	for i, r := range gets {
+		v, err := r.Result()
+		assert.Nil(t, err, "get failed")
+		assert.Equal(t, fmt.Sprintf("value_key%d", i), v, "replies out of order")
+	}
+	assert.True(t, errors.Is(missing.Err(), ErrNotFound), "not_found not reported")
+	assert.Equal(t, int64(7), incr.Val(), "incr failed")
+	assert.Equal(t, map[string]string{"k1": "v1", "k2": "v2"}, all.Val(), "hgetall failed")
+}
+
+func TestPipelineBroken(t *testing.T) {
+	host, port := replyServer(t, func(req []string) []string {
+		return []string{"ok", "1"}
+	})
+	db, err := Connect(host, port, conn_timeout, read_timeout, write_timeout)
+	if err != nil {
+		t.Fatalf("connect to server failed: %v", err)
+	}
+	p := db.Pipeline()
+	r := p.Get("key1")
+	db.Close()
+	err = p.Exec()
+	assert.NotNil(t, err, "exec on closed connection succeeded")
+	assert.Equal(t, err, r.Err(), "error not propagated to results")
+	assert.NotNil(t, db.Err(), "connection not marked broken")
+}
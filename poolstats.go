@@ -0,0 +1,123 @@
+package ssdb
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type PoolEventType int
+
+const (
+	//GetDB handed out an idle connection
+	EventHit PoolEventType = iota
+	//GetDB handed out a newly dialed connection
+	EventMiss
+	//GetDB waited for a connection to be returned, Duration is the wait time
+	EventWait
+	//a wait ended by PoolConfig.WaitTimeout or the context, Err tells which
+	EventTimeout
+	//a connection was dialed
+	EventCreate
+	//a connection was closed
+	EventClose
+	//a connection failed the health check, Err is the check error
+	EventHealthCheckFailure
+	//a connection was returned after more than PoolConfig.MaxBorrowTime,
+	//Duration is how long it was borrowed
+	EventBorrowedTooLong
+)
+
+//PoolEvent is passed to PoolConfig.Hook
+type PoolEvent struct {
+	Type     PoolEventType
+	Duration time.Duration
+	Err      error
+}
+
+//PoolHook receives the events counted in PoolStats, e.g. to export them
+//to a metrics system. It is called without the pool lock held, from the
+//goroutine that caused the event, and should return quickly.
+type PoolHook interface {
+	OnPoolEvent(ev PoolEvent)
+}
+
+//PoolStats is a snapshot of the pool state returned by SSDBPool.Stats
+type PoolStats struct {
+	IdleCount  int
+	UsedCount  int
+	TotalCount int
+	//GetDB calls blocked right now
+	Waiting int
+
+	Hits                uint64
+	Misses              uint64
+	Waits               uint64
+	WaitDuration        time.Duration
+	Timeouts            uint64
+	Created             uint64
+	Closed              uint64
+	HealthCheckFailures uint64
+	BorrowedTooLong     uint64
+}
+
+type poolCounters struct {
+	hits                atomic.Uint64
+	misses              atomic.Uint64
+	waits               atomic.Uint64
+	waitDuration        atomic.Int64
+	timeouts            atomic.Uint64
+	created             atomic.Uint64
+	closed              atomic.Uint64
+	healthCheckFailures atomic.Uint64
+	borrowedTooLong     atomic.Uint64
+}
+
+//counts ev and passes it to the hook, the caller must not hold the lock
+func (pool *SSDBPool) emit(ev PoolEvent) {
+	c := &pool.counters
+	switch ev.Type {
+	case EventHit:
+		c.hits.Add(1)
+	case EventMiss:
+		c.misses.Add(1)
+	case EventWait:
+		c.waits.Add(1)
+		c.waitDuration.Add(int64(ev.Duration))
+	case EventTimeout:
+		c.timeouts.Add(1)
+	case EventCreate:
+		c.created.Add(1)
+	case EventClose:
+		c.closed.Add(1)
+	case EventHealthCheckFailure:
+		c.healthCheckFailures.Add(1)
+	case EventBorrowedTooLong:
+		c.borrowedTooLong.Add(1)
+	}
+	if pool.poolconf.Hook != nil {
+		pool.poolconf.Hook.OnPoolEvent(ev)
+	}
+}
+
+//Stats returns a snapshot of the pool counters, it is safe for concurrent use
+func (pool *SSDBPool) Stats() PoolStats {
+	pool.lock.Lock()
+	stats := PoolStats{
+		IdleCount:  pool.idlecount,
+		UsedCount:  pool.usedcount,
+		TotalCount: pool.totalcount,
+		Waiting:    len(pool.waiters),
+	}
+	pool.lock.Unlock()
+	c := &pool.counters
+	stats.Hits = c.hits.Load()
+	stats.Misses = c.misses.Load()
+	stats.Waits = c.waits.Load()
+	stats.WaitDuration = time.Duration(c.waitDuration.Load())
+	stats.Timeouts = c.timeouts.Load()
+	stats.Created = c.created.Load()
+	stats.Closed = c.closed.Load()
+	stats.HealthCheckFailures = c.healthCheckFailures.Load()
+	stats.BorrowedTooLong = c.borrowedTooLong.Load()
+	return stats
+}
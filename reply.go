@@ -0,0 +1,92 @@
+package ssdb
+
+//Reply is the reply of a command sent with Do. Unlike a Response it keeps
+//its own copy of the blocks, so it stays valid after further commands.
+//Each accessor decodes the reply as the matching command methods do.
+type Reply struct {
+	rsp *Response
+}
+
+//Do sends any command, e.g. one the server has but Client lacks. The
+//arguments are encoded like those of every other command. A status other
+//than "ok" gives a *CommandError along with the reply. Retries follow
+//KindOf(cmd), so a command the client does not know is never retried.
+func (db *SSDB) Do(cmd string, args ...interface{}) (Reply, error) {
+	resp, err := db.do(cmd, args...)
+	if resp == nil {
+		return Reply{}, err
+	}
+	return newReply(resp), err
+}
+
+//Do queues any command, see SSDB.Do. As there, a status other than "ok"
+//gives a *CommandError along with the Reply of the result.
+func (p *Pipeline) Do(cmd string, args ...interface{}) *Result[Reply] {
+	r := &Result[Reply]{err: errNotExecuted}
+	//unlike queue, keeps the reply when the status is an error
+	p.cmds = append(p.cmds, pipelineCmd{cmd: cmd, args: args, reply: func(rsp *Response, err error) {
+		if err == nil {
+			r.val = newReply(rsp)
+			err = statusError(cmd, rsp)
+		}
+		r.err = err
+	}})
+	return r
+}
+
+//copies rsp out of the buffer of the connection
+func newReply(rsp *Response) Reply {
+	return Reply{rsp: &Response{
+		buf:  append([]byte(nil), rsp.buf...),
+		ends: append([]int(nil), rsp.ends...),
+	}}
+}
+
+//Status returns the status block, "" for an empty reply
+func (r Reply) Status() string {
+	return r.rsp.Status()
+}
+
+//Len returns the number of blocks following the status
+func (r Reply) Len() int {
+	if r.rsp.Len() == 0 {
+		return 0
+	}
+	return r.rsp.Len() - 1
+}
+
+//Text decodes a reply of a single block. It is not named String so that
+//Reply does not look like a fmt.Stringer.
+func (r Reply) Text() (string, error) {
+	return StringValue(r.rsp)
+}
+
+//Bytes decodes a reply of a single block
+func (r Reply) Bytes() ([]byte, error) {
+	return BytesValue(r.rsp)
+}
+
+//Int64 decodes a reply of a single integer block
+func (r Reply) Int64() (int64, error) {
+	return Int64(r.rsp)
+}
+
+//Bool decodes a reply of a single "1" or "0" block
+func (r Reply) Bool() (bool, error) {
+	return boolReply(r.rsp)
+}
+
+//Strings returns every block following the status
+func (r Reply) Strings() ([]string, error) {
+	return StringArray(r.rsp)
+}
+
+//StringMap decodes key/value blocks
+func (r Reply) StringMap() (map[string]string, error) {
+	return StringMap(r.rsp)
+}
+
+//Pairs decodes key/value blocks keeping their order
+func (r Reply) Pairs() (Pairs, error) {
+	return pairReply(r.rsp)
+}
@@ -0,0 +1,74 @@
+package ssdb
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	db := testDB(t)
+	r, err := db.Do("set", "k", "v\x00\n")
+	assert.Nil(t, err)
+	assert.Equal(t, "ok", r.Status())
+
+	get, err := db.Do("get", "k")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, get.Len())
+	db.Do("set", "k2", "other")
+	s, err := get.Text()
+	assert.Nil(t, err)
+	assert.Equal(t, "v\x00\n", s, "reply valid after further commands")
+	b, _ := get.Bytes()
+	assert.Equal(t, []byte("v\x00\n"), b)
+
+	r, _ = db.Do("incr", "n", 5)
+	n, err := r.Int64()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), n)
+	r, _ = db.Do("exists", "n")
+	ex, _ := r.Bool()
+	assert.True(t, ex)
+
+	r, _ = db.Do("multi_get", []string{"k", "k2"})
+	m, err := r.StringMap()
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"k": "v\x00\n", "k2": "other"}, m)
+	r, _ = db.Do("scan", "", "", 10)
+	pairs, err := r.Pairs()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"k", "k2", "n"}, pairs.Keys())
+	db.Do("qpush_back", "q", []string{"a", "b"})
+	r, _ = db.Do("qrange", "q", 0, 10)
+	items, err := r.Strings()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, items)
+
+	_, err = r.Int64()
+	protocolError := &ProtocolError{}
+	assert.ErrorAs(t, err, &protocolError, "wrong accessor")
+
+	r, err = db.Do("get", "missing")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, "not_found", r.Status(), "reply kept with the status error")
+	r, err = db.Do("no_such_command")
+	assert.True(t, errors.Is(err, ErrClientError))
+	assert.Equal(t, "client_error", r.Status())
+
+	_, err = db.Do("set", "k", struct{}{})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+	var empty Reply
+	assert.Equal(t, "", empty.Status())
+	_, err = empty.Text()
+	assert.ErrorAs(t, err, &protocolError, "zero Reply")
+
+	p := db.Pipeline()
+	pget := p.Do("get", "k2")
+	pmissing := p.Do("get", "missing")
+	assert.Nil(t, p.Exec())
+	s, _ = pget.Val().Text()
+	assert.Equal(t, "other", s)
+	assert.True(t, errors.Is(pmissing.Err(), ErrNotFound))
+	assert.Equal(t, "not_found", pmissing.Val().Status(), "pipelined reply kept with the status error")
+}
@@ -0,0 +1,153 @@
+package ssdb
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"sync"
+)
+
+const (
+	//largest block a reply may carry when no option sets it
+	default_max_block_size = 32 << 20
+	//largest reply, all blocks together, when no option sets it
+	default_max_response_size = 128 << 20
+	//buffers grown past this are not kept for the next reply
+	max_retained_buffer = 64 << 10
+)
+
+//Response is a reply of the server, the status block followed by the data
+//blocks. All blocks share one buffer that the connection reuses, so a
+//Response is only valid until the next Receive or Close on the connection
+//it came from. The decoders (StringValue, Int64, BytesValue...) copy what
+//they return.
+type Response struct {
+	buf []byte
+	//end offset of each block in buf, a block starts where the previous ends
+	ends []int
+}
+
+//buffers of closed connections, so a new connection starts with a grown one
+var response_pool = sync.Pool{New: func() any { return new(Response) }}
+
+//Len returns the number of blocks, the status included
+func (r *Response) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.ends)
+}
+
+//Block returns block i without copying it, 0 is the status
+func (r *Response) Block(i int) []byte {
+	start := 0
+	if i > 0 {
+		start = r.ends[i-1]
+	}
+	end := r.ends[i]
+	return r.buf[start:end:end]
+}
+
+//String returns a copy of block i
+func (r *Response) String(i int) string {
+	return string(r.Block(i))
+}
+
+//Status returns the status block, "" for an empty reply
+func (r *Response) Status() string {
+	if r.Len() == 0 {
+		return ""
+	}
+	return r.String(0)
+}
+
+func (r *Response) reset() {
+	if cap(r.buf) > max_retained_buffer {
+		r.buf = nil
+	}
+	r.buf = r.buf[:0]
+	r.ends = r.ends[:0]
+}
+
+//reads one reply into r. Sizes are parsed in place from the reader's
+//buffer and payloads are read straight into r.buf, so a reply allocates
+//nothing once r.buf is large enough.
+func (r *Response) read(reader *bufio.Reader, maxblock, maxresponse int) error {
+	r.reset()
+	for {
+		line, err := reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return &ProtocolError{Msg: "block size line too long"}
+		}
+		if err != nil {
+			return err
+		}
+		line = line[:len(line)-1]
+		if len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+		//end of packet
+		if len(line) == 0 {
+			return nil
+		}
+		size, ok := parseSize(line)
+		if !ok {
+			return &ProtocolError{Msg: "invalid block size " + strconv.Quote(string(line))}
+		}
+		if size > maxblock {
+			return &ProtocolError{Msg: "block of " + strconv.Itoa(size) + " bytes exceeds the limit of " + strconv.Itoa(maxblock)}
+		}
+		start := len(r.buf)
+		if start+size > maxresponse {
+			return &ProtocolError{Msg: "reply exceeds the limit of " + strconv.Itoa(maxresponse) + " bytes"}
+		}
+		r.buf = grow(r.buf, size)
+		if _, err := io.ReadFull(reader, r.buf[start:]); err != nil {
+			return err
+		}
+		if err := readEOL(reader); err != nil {
+			return err
+		}
+		r.ends = append(r.ends, len(r.buf))
+	}
+}
+
+//parses a non negative decimal size, at most 10 digits so it can not overflow
+func parseSize(line []byte) (int, bool) {
+	if len(line) > 10 {
+		return 0, false
+	}
+	n := 0
+	for _, c := range line {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+//extends buf by n bytes, reallocating only when its capacity is too small
+func grow(buf []byte, n int) []byte {
+	if len(buf)+n <= cap(buf) {
+		return buf[:len(buf)+n]
+	}
+	nb := make([]byte, len(buf)+n, 2*cap(buf)+n)
+	copy(nb, buf)
+	return nb
+}
+
+//reads the "\n" or "\r\n" ending a block
+func readEOL(reader *bufio.Reader) error {
+	b, err := reader.ReadByte()
+	if err == nil && b == '\r' {
+		b, err = reader.ReadByte()
+	}
+	if err != nil {
+		return err
+	}
+	if b != '\n' {
+		return &ProtocolError{Msg: "block not terminated by a newline"}
+	}
+	return nil
+}
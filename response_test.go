@@ -0,0 +1,184 @@
+package ssdb
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readString(s string, maxblock, maxresponse int) (*Response, error) {
+	r := new(Response)
+	return r, r.read(bufio.NewReader(strings.NewReader(s)), maxblock, maxresponse)
+}
+
+func blocks(r *Response) []string {
+	var res []string
+	for i := 0; i < r.Len(); i++ {
+		res = append(res, r.String(i))
+	}
+	return res
+}
+
+func TestResponseRead(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("2\nok\n0\n\n4\na\nb\n\n\n2\r\nok\r\n1\r\n\x00\r\n\r\n"))
+	r := new(Response)
+	assert.Nil(t, r.read(reader, 100, 100))
+	assert.Equal(t, []string{"ok", "", "a\nb\n"}, blocks(r))
+	assert.Equal(t, "ok", r.Status())
+	assert.Nil(t, r.read(reader, 100, 100), "second reply into the same response")
+	assert.Equal(t, []string{"ok", "\x00"}, blocks(r), "\\r\\n line ends")
+	assert.Equal(t, io.EOF, r.read(reader, 100, 100))
+
+	protocolError := &ProtocolError{}
+	tests := []struct {
+		name  string
+		reply string
+		err   interface{}
+	}{
+		{"invalid size", "x\nok\n\n", &protocolError},
+		{"negative size", "-1\nok\n\n", &protocolError},
+		{"huge size", "99999999999\nok\n\n", &protocolError},
+		{"block too large", "11\nhello world\n\n", &protocolError},
+		{"reply too large", "8\nhello wo\n8\nrld hell\n8\no world!\n\n", &protocolError},
+		{"missing newline", "2\nokX\n", &protocolError},
+		{"truncated block", "5\nok", io.ErrUnexpectedEOF},
+		{"truncated reply", "2\nok\n", io.EOF},
+	}
+	for _, test := range tests {
+		_, err := readString(test.reply, 10, 20)
+		if target, ok := test.err.(error); ok {
+			assert.ErrorIs(t, err, target, test.name)
+		} else {
+			assert.ErrorAs(t, err, test.err, test.name)
+		}
+	}
+
+	long := bufio.NewReaderSize(strings.NewReader(strings.Repeat("1", 32)+"\n"), 16)
+	err := new(Response).read(long, 10, 20)
+	assert.ErrorAs(t, err, &protocolError, "size line longer than the read buffer")
+}
+
+func TestResponseLimits(t *testing.T) {
+	srv := testServer(t)
+	db, err := Dial(srv.Addr(), WithMaxBlockSize(16), WithMaxResponseSize(40))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer db.Close()
+	db.Set("small", "v")
+	db.Set("large", strings.Repeat("x", 17))
+
+	_, err = db.Get("large")
+	protocolError := &ProtocolError{}
+	assert.ErrorAs(t, err, &protocolError, "block over the limit")
+	assert.NotNil(t, db.Err(), "connection broken")
+	v, err := db.Get("small")
+	assert.Nil(t, err, "reconnected")
+	assert.Equal(t, "v", v)
+
+	for i := 0; i < 4; i++ {
+		db.Set("k"+strconv.Itoa(i), strings.Repeat("y", 10))
+	}
+	_, err = db.MultiGet([]string{"k0", "k1", "k2", "k3"})
+	assert.ErrorAs(t, err, &protocolError, "reply over the limit")
+}
+
+//a reply of a status and n blocks of size bytes
+func testReply(n, size int) []byte {
+	buf := appendBlock(nil, []byte("ok"))
+	for i := 0; i < n; i++ {
+		buf = appendBlock(buf, bytes.Repeat([]byte{'v'}, size))
+	}
+	return append(buf, '\n')
+}
+
+func TestResponseAllocs(t *testing.T) {
+	data := testReply(10, 100)
+	src := bytes.NewReader(data)
+	reader := bufio.NewReader(src)
+	r := new(Response)
+	allocs := testing.AllocsPerRun(100, func() {
+		src.Reset(data)
+		reader.Reset(src)
+		if err := r.read(reader, default_max_block_size, default_max_response_size); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Equal(t, 0.0, allocs, "allocations per reply")
+	assert.Equal(t, 11, r.Len())
+}
+
+//the parser Response replaced, with the recursive readFully turned into
+//io.ReadFull, kept to compare against
+func legacyReceive(reader *bufio.Reader) ([]bytes.Buffer, error) {
+	var bufArray = []bytes.Buffer{}
+	for {
+		var sizebuf bytes.Buffer
+		for b, er := reader.ReadByte(); b != '\n'; b, er = reader.ReadByte() {
+			if er != nil {
+				return nil, er
+			}
+			if b != '\r' {
+				sizebuf.WriteByte(b)
+			}
+		}
+		if sizebuf.Len() == 0 {
+			return bufArray, nil
+		}
+		size, er := strconv.Atoi(sizebuf.String())
+		if er != nil {
+			return nil, er
+		}
+		var dataBuf bytes.Buffer
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+		dataBuf.Write(buf)
+		for b, er := reader.ReadByte(); b != '\n'; b, er = reader.ReadByte() {
+			if er != nil {
+				return nil, er
+			}
+		}
+		bufArray = append(bufArray, dataBuf)
+	}
+}
+
+func benchmarkReceive(b *testing.B, n, size int, read func(*bufio.Reader) error) {
+	data := testReply(n, size)
+	src := bytes.NewReader(data)
+	reader := bufio.NewReader(src)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		src.Reset(data)
+		reader.Reset(src)
+		if err := read(reader); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReceive(b *testing.B) {
+	for _, shape := range []struct{ n, size int }{{1, 16}, {20, 64}, {4, 4096}} {
+		name := strconv.Itoa(shape.n) + "x" + strconv.Itoa(shape.size)
+		b.Run(name, func(b *testing.B) {
+			r := new(Response)
+			benchmarkReceive(b, shape.n, shape.size, func(reader *bufio.Reader) error {
+				return r.read(reader, default_max_block_size, default_max_response_size)
+			})
+		})
+		b.Run(name+"/legacy", func(b *testing.B) {
+			benchmarkReceive(b, shape.n, shape.size, func(reader *bufio.Reader) error {
+				_, err := legacyReceive(reader)
+				return err
+			})
+		})
+	}
+}
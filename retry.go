@@ -0,0 +1,143 @@
+package ssdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+//CommandKind tells whether sending a command twice is safe, see KindOf.
+//Kinds are bit flags so a RetryPolicy can select several.
+type CommandKind int
+
+const (
+	//does not change data
+	ReadCommand CommandKind = 1 << iota
+	//applying it twice leaves the same data, though the second reply may
+	//differ, e.g. HSet reports the field as existing
+	IdempotentWrite
+	//applying it twice changes data twice, e.g. Incr or QPushBack
+	NonIdempotentWrite
+)
+
+func (k CommandKind) String() string {
+	switch k {
+	case ReadCommand:
+		return "read"
+	case IdempotentWrite:
+		return "idempotent write"
+	case NonIdempotentWrite:
+		return "non-idempotent write"
+	}
+	return "CommandKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+//KindOf returns the kind of a protocol command such as "get" or
+//"qpush_back"; unknown commands are NonIdempotentWrite
+func KindOf(cmd string) CommandKind {
+	if k, ok := command_kinds[cmd]; ok {
+		return k
+	}
+	return NonIdempotentWrite
+}
+
+//RetryPolicy sends a command again after a transient failure such as a
+//dropped connection. The zero value does not retry.
+type RetryPolicy struct {
+	//attempts including the first one, below 2 means no retry
+	MaxAttempts int
+	//wait before the first retry, doubled for each further one up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	//randomizes each wait by up to this fraction, 0.2 waits 80% to 120%
+	Jitter float64
+	//reports whether a failure is worth a retry, nil means IsTransient
+	Retryable func(err error) bool
+	//kinds of commands retried, 0 means ReadCommand|IdempotentWrite.
+	//Retrying NonIdempotentWrite may apply a write twice.
+	Kinds CommandKind
+}
+
+//DefaultRetryPolicy is a starting point for WithRetryPolicy and PoolConfig.Retry
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  50 * time.Millisecond,
+	MaxBackoff:  time.Second,
+	Jitter:      0.2,
+}
+
+//IsTransient reports whether err comes from the network rather than the
+//server: timeouts, connect timeouts included, resets, refused or dropped
+//connections. A retry never outlives the context of the command, so a
+//deadline that passed is not retried either way.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+func (p *RetryPolicy) retries(kind CommandKind) bool {
+	kinds := p.Kinds
+	if kinds == 0 {
+		kinds = ReadCommand | IdempotentWrite
+	}
+	return p.MaxAttempts > 1 && kinds&kind != 0
+}
+
+//wait before retry n, counting from 1
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+//runs fn, then again while it fails with a retryable error, attempts
+//remain and ctx is not done. ctx may be nil.
+func (p *RetryPolicy) run(ctx context.Context, kind CommandKind, fn func() error) error {
+	err := fn()
+	if err == nil || !p.retries(kind) {
+		return err
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsTransient
+	}
+	for n := 1; n < p.MaxAttempts && retryable(err); n++ {
+		if d := p.backoff(n); d > 0 {
+			timer := time.NewTimer(d)
+			if ctx == nil {
+				<-timer.C
+			} else {
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return err
+				}
+			}
+		}
+		if ctx != nil && ctx.Err() != nil {
+			return err
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
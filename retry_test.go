@@ -0,0 +1,163 @@
+package ssdb
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//every command sent by the client must have a kind
+func TestCommandKinds(t *testing.T) {
+	fset := token.NewFileSet()
+	sent := map[string]bool{}
+	for _, file := range []string{"ssdb.go", "admin.go", "bytes.go", "pipeline.go"} {
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			arg := -1
+			switch fn := call.Fun.(type) {
+			case *ast.SelectorExpr:
+				if fn.Sel.Name == "do" {
+					arg = 0
+				}
+			case *ast.Ident:
+				if fn.Name == "queue" {
+					arg = 2
+				}
+			}
+			if arg >= 0 && arg < len(call.Args) {
+				if lit, ok := call.Args[arg].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					cmd, _ := strconv.Unquote(lit.Value)
+					sent[cmd] = true
+				}
+			}
+			return true
+		})
+	}
+	assert.True(t, len(sent) > 80, "commands found")
+	for cmd := range sent {
+		_, ok := command_kinds[cmd]
+		assert.True(t, ok, "no kind for "+cmd)
+	}
+
+	assert.Equal(t, ReadCommand, KindOf("get"))
+	assert.Equal(t, IdempotentWrite, KindOf("set"))
+	assert.Equal(t, NonIdempotentWrite, KindOf("incr"))
+	assert.Equal(t, NonIdempotentWrite, KindOf("unknown"), "unknown commands are unsafe")
+	assert.Equal(t, "idempotent write", IdempotentWrite.String())
+}
+
+func TestIsTransient(t *testing.T) {
+	//a dial that cannot finish in time, as against an unroutable address
+	d := net.Dialer{Timeout: time.Nanosecond}
+	_, timeout := d.Dial("tcp", "10.255.255.1:8888")
+	assert.ErrorIs(t, timeout, context.DeadlineExceeded)
+	assert.True(t, IsTransient(timeout), "connect timeout")
+	assert.True(t, IsTransient(io.EOF))
+	assert.True(t, IsTransient(&TimeoutError{Op: "dial", Err: timeout}))
+	assert.True(t, IsTransient(&net.OpError{Op: "dial", Err: errors.New("refused")}))
+	assert.False(t, IsTransient(nil))
+	assert.False(t, IsTransient(&CommandError{Cmd: "get", Status: "error"}))
+	assert.False(t, IsTransient(ErrBroken))
+	assert.False(t, IsTransient(context.Canceled))
+}
+
+func TestRetryPolicy(t *testing.T) {
+	srv := testServer(t)
+	db, err := Dial(srv.Addr(), WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer db.Close()
+	db.Set("k", "v")
+
+	srv.DropConns()
+	v, err := db.Get("k")
+	assert.Nil(t, err, "read retried")
+	assert.Equal(t, "v", v)
+	srv.DropConns()
+	assert.Nil(t, db.Set("k", "v2"), "idempotent write retried")
+	srv.DropConns()
+	_, err = db.Incr("n", 1)
+	assert.True(t, IsTransient(err), "non-idempotent write not retried")
+
+	db.opts.retry = RetryPolicy{MaxAttempts: 3, Kinds: ReadCommand}
+	db.Ping()
+	srv.DropConns()
+	assert.NotNil(t, db.Set("k", "v3"), "writes excluded by Kinds")
+
+	retried := 0
+	db.opts.retry = RetryPolicy{MaxAttempts: 3, Retryable: func(err error) bool { retried++; return false }}
+	db.Ping()
+	srv.DropConns()
+	_, err = db.Get("k")
+	assert.NotNil(t, err, "Retryable refused")
+	assert.Equal(t, 1, retried)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	db.opts.retry = RetryPolicy{MaxAttempts: 3, MinBackoff: time.Hour}
+	calls := 0
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	err = db.opts.retry.run(ctx, ReadCommand, func() error { calls++; return io.EOF })
+	assert.Equal(t, io.EOF, err, "backoff interrupted by the context")
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 35 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, p.backoff(1))
+	assert.Equal(t, 20*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 35*time.Millisecond, p.backoff(3), "backoff capped")
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.backoff(1)
+		assert.True(t, d >= 5*time.Millisecond && d <= 15*time.Millisecond, "jitter within bounds")
+	}
+}
+
+func TestPoolRetry(t *testing.T) {
+	srv := testServer(t)
+	dials := 0
+	dialer := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		if dials == 1 {
+			return nil, &net.OpError{Op: "dial", Err: errors.New("refused")}
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+	pool, err := NewPool(PoolConfig{Host: srv.Host(), Port: srv.Port(), Max_conn_count: 1,
+		Retry: RetryPolicy{MaxAttempts: 2}, DialOptions: []Option{WithDialer(dialer)}})
+	if err != nil {
+		t.Fatalf("new pool failed: %v", err)
+	}
+	defer pool.Close()
+	db, err := pool.GetDB()
+	assert.Nil(t, err, "dial retried")
+	assert.Equal(t, 2, dials)
+
+	db.Set("k", "v")
+	srv.DropConns()
+	v, err := db.Get("k")
+	assert.Nil(t, err, "pooled connection retries reads")
+	assert.Equal(t, "v", v)
+	pool.ReturnDB(db)
+}
@@ -1,8 +1,7 @@
 package ssdb
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -10,24 +9,28 @@ import (
 	"time"
 )
 
-type Error string
-
 type Conn interface {
 	//close the tcp connection
 	Close() error
 	//return non-nil value if the connection is broken
 	Err() error
 	//sends a command to the server and returns the received response
-	Do(cmd string, args []interface{}) (rsp []bytes.Buffer, err error)
+	Do(cmd string, args []interface{}) (rsp *Response, err error)
+	//like Do, but aborts the request and marks the connection broken when ctx is done
+	DoContext(ctx context.Context, cmd string, args []interface{}) (rsp *Response, err error)
 	//sends a command to the server
 	Send(cmd string, args []interface{}) error
 	//flushes the output buffer to the server
 	Flush() error
-	//receives a single reply from server
-	Receive() (rsp []bytes.Buffer, err error)
+	//receives a single reply from server, it is only valid until the next
+	//Receive or Close, see Response
+	Receive() (rsp *Response, err error)
 }
 
 type Client interface {
+	Ping() error
+	//sends any command, for those without a method
+	Do(cmd string, args ...interface{}) (Reply, error)
 	Set(key string, value string) error
 	Get(key string) (result string, err error)
 	Del(key string) (bool, error)
@@ -35,20 +38,45 @@ type Client interface {
 	//  key_start<key<=key_end
 	Keys(key_start, key_end string, limit int) ([]string, error)
 	// key_start<key<=key_end
-	Scan(key_start, key_end string, limit int) (map[string]string, error)
+	Scan(key_start, key_end string, limit int) (Pairs, error)
 	// key_end<=key<key_start
-	RScan(key_start, key_end string, limit int) (map[string]string, error)
+	RScan(key_start, key_end string, limit int) (Pairs, error)
 	Incr(key string, by int64) (value int64, err error)
+	//sets key to expire after ttl, rounded up to whole seconds
+	SetX(key, value string, ttl time.Duration) error
+	//reports whether key was set, an existing key is left untouched
+	SetNX(key, value string) (bool, error)
+	//sets key and returns its previous value, ErrNotFound if there was none
+	GetSet(key, value string) (string, error)
+	//reports whether key exists
+	Expire(key string, ttl time.Duration) (bool, error)
+	//returns NoTTL when key has no ttl or does not exist
+	TTL(key string) (time.Duration, error)
+	//sets the bit at offset of the value of key and returns its previous state,
+	//bit offset%8 of byte offset/8 counting from the least significant bit
+	SetBit(key string, offset int64, on bool) (bool, error)
+	GetBit(key string, offset int64) (bool, error)
+	//counts the set bits in bytes start<=index<=end, negative indexes count from the end
+	BitCount(key string, start, end int64) (int64, error)
+	//counts the set bits in the bytes Substr(key, start, size) returns
+	CountBit(key string, start, size int64) (int64, error)
+	//returns size bytes from start, a negative start counts from the end and
+	//a negative size leaves out that many bytes at the end
+	Substr(key string, start, size int64) (string, error)
+	StrLen(key string) (int64, error)
 	MultiSet(kvs []string) (bool, error)
+	//like SetX for every key/value pair, in a single round trip
+	MultiSetX(kvs []string, ttl time.Duration) error
 	MultiGet(keys []string) (map[string]string, error)
 	MultiDel(keys []string) (bool, error)
 
 	ZSet(setname, key string, score int64) error
 	ZGet(setname, key string) (int64, error)
 	ZIncr(setname, key string, by int64) (value int64, err error)
-	ZDel(sentname, key string) (bool, error)
+	//reports whether key existed
+	ZDel(setname, key string) (bool, error)
 	ZSize(setname string) (int64, error)
-	ZScan(setname, keystart string, score_start, score_end int64, limit int) (map[string]int64, error)
+	ZScan(setname, keystart string, score_start, score_end int64, limit int) (ZPairs, error)
 	// name_start<name<=name_end
 	ZList(name_start, name_end string, limit int) ([]string, error)
 	ZClear(setname string) error
@@ -56,11 +84,39 @@ type Client interface {
 	ZExists(setname, key string) (bool, error)
 	// key_start<key   score_start<=score<=score_end
 	ZKeys(setname, key_start string, score_start, score_end int64, limit int) ([]string, error)
-	MultiZGet(setname string, keys []string) (map[string]int64, error)
+	MultiZGet(setname string, keys []string) (ZPairs, error)
 	MultiZset(setname string, kvs map[string]int64) error
-
+	//reports whether the keys were deleted, missing keys are ignored
+	MultiZDel(setname string, keys []string) (bool, error)
+	//0 based rank of key by ascending score
+	ZRank(setname, key string) (int64, error)
+	//0 based rank of key by descending score
+	ZRrank(setname, key string) (int64, error)
+	//limit pairs from rank offset by ascending score
+	ZRange(setname string, offset, limit int) (ZPairs, error)
+	//limit pairs from rank offset by descending score
+	ZRrange(setname string, offset, limit int) (ZPairs, error)
+	// key<key_start   score_start>=score>=score_end, by descending score
+	ZRscan(setname, key_start string, score_start, score_end int64, limit int) (ZPairs, error)
+	// name_end<=name<name_start
+	ZRlist(name_start, name_end string, limit int) ([]string, error)
+	//deletes the keys ranked start<=rank<=end and returns how many were deleted
+	ZRemRangeByRank(setname string, start, end int64) (int64, error)
+	//deletes the keys with score_start<=score<=score_end and returns how many were deleted
+	ZRemRangeByScore(setname string, score_start, score_end int64) (int64, error)
+	// score_start<=score<=score_end
+	ZSum(setname string, score_start, score_end int64) (int64, error)
+	// score_start<=score<=score_end
+	ZAvg(setname string, score_start, score_end int64) (float64, error)
+	//deletes and returns up to limit pairs with the lowest scores
+	ZPopFront(setname string, limit int) (ZPairs, error)
+	//deletes and returns up to limit pairs with the highest scores
+	ZPopBack(setname string, limit int) (ZPairs, error)
+
+	//reports whether key was newly created
 	HSet(name, key, value string) (bool, error)
 	HGet(name, key string) (string, error)
+	//reports whether key existed
 	HDel(name, key string) (bool, error)
 	HIncr(name, key string, by int64) (int64, error)
 	HExists(name, key string) (bool, error)
@@ -70,17 +126,31 @@ type Client interface {
 	HRlist(name_start, name_end string, limit int) ([]string, error)
 	HKeys(name, key_start, key_end string, limit int) ([]string, error)
 	HGetAll(name string) (map[string]string, error)
-	HScan(name, key_start, key_end string, limit int) (map[string]string, error)
-	HRscan(name, key_start, key_end string, limit int) (map[string]string, error)
+	HScan(name, key_start, key_end string, limit int) (Pairs, error)
+	HRscan(name, key_start, key_end string, limit int) (Pairs, error)
 	HClear(name string) (bool, error)
 	MultiHSet(name string, kvs []string) (bool, error)
 	MultiHGet(name string, keys []string) (map[string]string, error)
 	MultiHDel(name string, keys []string) (bool, error)
 
-	QPushFront(name, value string) (int64, error)
-	QPushBack(name, value string) (int64, error)
+	//pushes the items one after another in one round trip and returns the
+	//queue size, QPushFront leaves the last item at the front
+	QPushFront(name string, items ...string) (int64, error)
+	QPushBack(name string, items ...string) (int64, error)
 	QPopFront(name string) (string, error)
 	QPopBack(name string) (string, error)
+	//pops up to size items, an empty queue gives an empty slice
+	QPopFrontN(name string, size int) ([]string, error)
+	QPopBackN(name string, size int) ([]string, error)
+	//deletes up to size items and returns how many were deleted
+	QTrimFront(name string, size int) (int64, error)
+	QTrimBack(name string, size int) (int64, error)
+	//replaces the item at index, a negative index counts from the end
+	QSet(name string, index int64, value string) error
+	//limit items from offset, a negative offset counts from the end
+	QRange(name string, offset, limit int) ([]string, error)
+	//repairs the size of a queue whose metadata got out of sync
+	QFix(name string) error
 	QSize(name string) (int64, error)
 	QList(name_start, name_end string, limit int) ([]string, error)
 	QRlist(name_start, name_end string, limit int) ([]string, error)
@@ -88,81 +158,239 @@ type Client interface {
 	QFront(name string) (string, error)
 	QBack(name string) (string, error)
 	QGet(name string, index int64) (string, error)
-	//  begin< index <=end
+	//  begin<=index<=end, negative indexes count from the end
 	QSlice(name string, begin, end int64) ([]string, error)
-}
 
-func connect(host string, port int, conntimeout, readtimeout, writetimeout time.Duration) (Conn, error) {
-	c := &conn{}
-	connection, er := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", host, port), conntimeout)
-	if er != nil {
-		return nil, er
-	}
-	c.client = connection
-	c.writer = bufio.NewWriter(connection)
-	c.reader = bufio.NewReader(connection)
-	return c, nil
+	//[]byte variants of the commands above, for binary values
+	SetBytes(key string, value []byte) error
+	GetBytes(key string) ([]byte, error)
+	SetXBytes(key string, value []byte, ttl time.Duration) error
+	GetSetBytes(key string, value []byte) ([]byte, error)
+	MultiSetBytes(kvs map[string][]byte) error
+	MultiGetBytes(keys []string) (map[string][]byte, error)
+	HSetBytes(name, key string, value []byte) (bool, error)
+	HGetBytes(name, key string) ([]byte, error)
+	HGetAllBytes(name string) (map[string][]byte, error)
+	MultiHSetBytes(name string, kvs map[string][]byte) error
+	MultiHGetBytes(name string, keys []string) (map[string][]byte, error)
+	QPushFrontBytes(name string, items ...[]byte) (int64, error)
+	QPushBackBytes(name string, items ...[]byte) (int64, error)
+	QPopFrontBytes(name string) ([]byte, error)
+	QPopBackBytes(name string) ([]byte, error)
+	QFrontBytes(name string) ([]byte, error)
+	QBackBytes(name string) ([]byte, error)
+	QGetBytes(name string, index int64) ([]byte, error)
+	QSetBytes(name string, index int64, value []byte) error
+	QRangeBytes(name string, offset, limit int) ([][]byte, error)
+
+	//iterators paging through the range commands
+	ScanIterator(key_start, key_end string) *ScanIterator
+	HScanIterator(name, key_start, key_end string) *HScanIterator
+	ZScanIterator(name string, score_start, score_end int64) *ZScanIterator
+	QRangeIterator(name string) *QRangeIterator
+	HListIterator(name_start, name_end string) *ListIterator
+	ZListIterator(name_start, name_end string) *ListIterator
+	QListIterator(name_start, name_end string) *ListIterator
 }
 
+//Connect dials host:port, Dial takes more options. Like Dial it returns
+//a closed client along with an error.
 func Connect(host string, port int, conntimeout, readtimeout, writetimeout time.Duration) (*SSDB, error) {
-	conn, err := connect(host, port, conntimeout, readtimeout, writetimeout)
-	return &SSDB{conn: conn}, err
+	return ConnectAuth(host, port, "", conntimeout, readtimeout, writetimeout)
 }
 
+//ConnectAuth is Connect for a server requiring a password. The password is
+//sent again whenever the connection is reopened by (*SSDB).Connect.
+func ConnectAuth(host string, port int, password string, conntimeout, readtimeout, writetimeout time.Duration) (*SSDB, error) {
+	return Dial(net.JoinHostPort(host, strconv.Itoa(port)),
+		WithPassword(password), WithConnTimeout(conntimeout), WithReadTimeout(readtimeout), WithWriteTimeout(writetimeout))
+}
+
+//New returns a client that connects on its first command, so a server
+//that is down is reported by the command rather than by New
 func New(host string, port int, conntimeout, readtimeout, writetimeout time.Duration) (*SSDB, error) {
+	return NewClient(net.JoinHostPort(host, strconv.Itoa(port)),
+		WithConnTimeout(conntimeout), WithReadTimeout(readtimeout), WithWriteTimeout(writetimeout)), nil
+}
 
-	return &SSDB{}, nil
+//NewClient is Dial without connecting, the first command connects
+func NewClient(addr string, opts ...Option) *SSDB {
+	return &SSDB{session: &session{addr: addr, opts: newOptions(opts)}}
 }
 
+//SSDB is a client on a single connection, it is not safe for concurrent use.
+//A broken connection is reopened by the next command, see WithReconnect.
 type SSDB struct {
-	conn         Conn
-	host         string
-	port         int
-	readtimeout  time.Duration
-	writetimeout time.Duration
-	conntimeout  time.Duration
+	*session
+	ctx context.Context
+}
+
+//connection state shared by a client and its WithContext views
+type session struct {
+	//nil until the first command of a client created by New
+	conn Conn
+	addr string
+	opts options
+	//set by Close, commands fail until Connect is called
+	closed bool
+	//consecutive failed dials, the next one waits until nextdial
+	failures int
+	nextdial time.Time
+	//error of the last dial, reported by Err while conn is nil
+	dialerr error
 }
 
+//Err returns the error that broke the connection, or that made the last
+//dial fail, nil if it is usable or not opened yet, ErrBroken once closed
 func (db *SSDB) Err() error {
+	if db.conn == nil {
+		if db.closed {
+			return ErrBroken
+		}
+		return db.dialerr
+	}
 	return db.conn.Err()
 }
 
+//Connect opens a new connection, closing the current one
 func (db *SSDB) Connect() error {
-	if db.conn != nil && db.conn.Err() == nil {
+	if db.conn != nil {
 		db.conn.Close()
 	}
-	conn, err := connect(db.host, db.port, db.conntimeout, db.readtimeout, db.writetimeout)
+	db.closed = false
+	conn, err := connect(db.addr, &db.opts)
+	if err != nil {
+		db.opts.logf("ssdb: connect to %s failed: %v", db.addr, err)
+		db.conn = nil
+		db.dialerr = err
+		return err
+	}
 	db.conn = conn
-	return err
-
+	db.dialerr = nil
+	return nil
 }
 
+//Close closes the connection, later commands return ErrBroken
+//unless Connect is called
 func (db *SSDB) Close() {
-	_ = db.conn.Close()
+	db.closed = true
+	if db.conn != nil {
+		_ = db.conn.Close()
+	}
 }
 
-func (db *SSDB) Set(key string, value string) error {
-	resp, err := db.conn.Do("set", []interface{}{key, value})
-	if err != nil {
+//returns the connection, opening it on first use and reopening it once
+//broken. Failed dials are retried no sooner than the reconnect backoff.
+func (db *SSDB) getConn() (Conn, error) {
+	if db.closed && db.conn == nil {
+		//closed before the first command, Close still applies
+		return nil, ErrBroken
+	}
+	if db.conn != nil && (db.conn.Err() == nil || db.closed || !db.opts.reconnect) {
+		return db.conn, nil
+	}
+	if db.failures > 0 {
+		if wait := time.Until(db.nextdial); wait > 0 {
+			return nil, fmt.Errorf("ssdb: reconnect to %s in %v: %w", db.addr, wait.Round(time.Millisecond), db.dialerr)
+		}
+	}
+	if db.conn != nil {
+		db.opts.logf("ssdb: reconnecting to %s after: %v", db.addr, db.conn.Err())
+	}
+	if err := db.Connect(); err != nil {
+		db.failures++
+		db.nextdial = time.Now().Add(db.opts.backoff(db.failures))
+		return nil, err
+	}
+	db.failures = 0
+	return db.conn, nil
+}
+
+//WithContext returns a view of db whose commands are bound to ctx.
+//The view shares the underlying connection with db; when ctx is canceled
+//or its deadline passes, the in-flight command is aborted and the
+//connection is marked broken.
+func (db *SSDB) WithContext(ctx context.Context) *SSDB {
+	if ctx == nil {
+		panic("nil context")
+	}
+	view := *db
+	view.ctx = ctx
+	return &view
+}
+
+//Context returns the context bound by WithContext, or context.Background
+func (db *SSDB) Context() context.Context {
+	if db.ctx != nil {
+		return db.ctx
+	}
+	return context.Background()
+}
+
+//sends cmd and returns its reply, a status other than "ok" is returned
+//as a *CommandError along with the reply
+func (db *SSDB) do(cmd string, args ...interface{}) (*Response, error) {
+	var resp *Response
+	attempts := 0
+	err := db.opts.retry.run(db.ctx, KindOf(cmd), func() (err error) {
+		if attempts > 0 {
+			db.opts.logf("ssdb: retrying %s on %s, attempt %d", cmd, db.addr, attempts+1)
+		}
+		attempts++
+		resp, err = db.roundtrip(cmd, args)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	if resp[0].String() == "ok" {
-		return nil
+	return resp, statusError(cmd, resp)
+}
+
+//sends cmd on the current connection and returns the raw reply
+func (db *SSDB) roundtrip(cmd string, args []interface{}) (*Response, error) {
+	conn, err := db.getConn()
+	if err != nil {
+		return nil, err
+	}
+	var resp *Response
+	if db.ctx != nil {
+		resp, err = conn.DoContext(db.ctx, cmd, args)
 	} else {
-		return errors.New(resp[0].String())
+		resp, err = conn.Do(cmd, args)
+	}
+	if err != nil && err != ErrBroken {
+		db.opts.logf("ssdb: %s on %s failed: %v", cmd, db.addr, err)
+	}
+	return resp, err
+}
+
+//Ping checks that the server is alive without touching any data
+func (db *SSDB) Ping() error {
+	resp, err := db.do("ping")
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) Set(key string, value string) error {
+	resp, err := db.do("set", key, value)
+	if err != nil {
+		return err
 	}
+	return checkStatus(resp)
 }
 
 func (db *SSDB) MultiSet(kvs []string) (bool, error) {
-	resp, err := db.conn.Do("multi_set", []interface{}{kvs})
+	resp, err := db.do("multi_set", kvs)
 	if err != nil {
 		return false, err
 	}
-	return resp[0].String() == "ok", nil
+	return BoolValue(resp)
 }
 
 func (db *SSDB) Get(key string) (string, error) {
-	resp, err := db.conn.Do("get", []interface{}{key})
+	resp, err := db.do("get", key)
 	if err != nil {
 		return "", err
 	}
@@ -172,7 +400,7 @@ func (db *SSDB) Get(key string) (string, error) {
 
 func (db *SSDB) MultiGet(keys []string) (map[string]string, error) {
 
-	resp, err := db.conn.Do("multi_get", []interface{}{keys})
+	resp, err := db.do("multi_get", keys)
 	if err != nil {
 		return nil, err
 	}
@@ -181,47 +409,43 @@ func (db *SSDB) MultiGet(keys []string) (map[string]string, error) {
 }
 func (db *SSDB) MultiDel(keys []string) (bool, error) {
 
-	resp, err := db.conn.Do("multi_del", []interface{}{keys})
+	resp, err := db.do("multi_del", keys)
 	if err != nil {
 		return false, err
 	}
-	if resp[0].String() != "ok" {
-		return false, errors.New(resp[0].String())
-	}
-
-	return true, nil
+	return BoolValue(resp)
 }
 
-func (db *SSDB) Scan(key_start, key_end string, limit int) (map[string]string, error) {
+func (db *SSDB) Scan(key_start, key_end string, limit int) (Pairs, error) {
 
-	resp, err := db.conn.Do("scan", []interface{}{key_start, key_end, limit})
+	resp, err := db.do("scan", key_start, key_end, limit)
 	if err != nil {
 		return nil, err
 	}
-	return StringMap(resp)
+	return pairReply(resp)
 }
 
-func (db *SSDB) RScan(key_start, key_end string, limit int) (map[string]string, error) {
+func (db *SSDB) RScan(key_start, key_end string, limit int) (Pairs, error) {
 
-	resp, err := db.conn.Do("rscan", []interface{}{key_start, key_end, limit})
+	resp, err := db.do("rscan", key_start, key_end, limit)
 	if err != nil {
 		return nil, err
 	}
-	return StringMap(resp)
+	return pairReply(resp)
 
 }
 
 func (db *SSDB) Del(key string) (bool, error) {
-	resp, err := db.conn.Do("del", []interface{}{key})
+	resp, err := db.do("del", key)
 	if err != nil {
 		return false, err
 	}
-	return resp[0].String() == "ok", nil
+	return BoolValue(resp)
 
 }
 
 func (db *SSDB) Keys(key_start, key_end string, limit int) ([]string, error) {
-	resp, err := db.conn.Do("keys", []interface{}{key_start, key_end, limit})
+	resp, err := db.do("keys", key_start, key_end, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -230,19 +454,145 @@ func (db *SSDB) Keys(key_start, key_end string, limit int) ([]string, error) {
 
 func (db *SSDB) Exists(key string) (bool, error) {
 
-	resp, err := db.conn.Do("exists", []interface{}{key})
+	resp, err := db.do("exists", key)
 	if err != nil {
 		return false, err
 	}
-	if resp[0].String() != "ok" {
-		return false, errors.New(resp[0].String())
-	}
-	return resp[1].String() == "1", nil
+	return boolReply(resp)
 
 }
 
 func (db *SSDB) Incr(key string, by int64) (int64, error) {
-	resp, err := db.conn.Do("incr", []interface{}{key, by})
+	resp, err := db.do("incr", key, by)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(resp)
+}
+
+//NoTTL is returned by TTL for a key that never expires
+const NoTTL time.Duration = -1
+
+func (db *SSDB) SetX(key, value string, ttl time.Duration) error {
+	secs, err := ttlSeconds(ttl)
+	if err != nil {
+		return err
+	}
+	resp, err := db.do("setx", key, value, secs)
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) SetNX(key, value string) (bool, error) {
+	resp, err := db.do("setnx", key, value)
+	if err != nil {
+		return false, err
+	}
+	return boolReply(resp)
+}
+
+//GetSet stores value even when it returns ErrNotFound
+func (db *SSDB) GetSet(key, value string) (string, error) {
+	resp, err := db.do("getset", key, value)
+	if err != nil {
+		return "", err
+	}
+	return StringValue(resp)
+}
+
+func (db *SSDB) Expire(key string, ttl time.Duration) (bool, error) {
+	secs, err := ttlSeconds(ttl)
+	if err != nil {
+		return false, err
+	}
+	resp, err := db.do("expire", key, secs)
+	if err != nil {
+		return false, err
+	}
+	return boolReply(resp)
+}
+
+func (db *SSDB) TTL(key string) (time.Duration, error) {
+	resp, err := db.do("ttl", key)
+	if err != nil {
+		return 0, err
+	}
+	return ttlReply(resp)
+}
+
+//SSDB has no multi_setx, so MultiSetX pipelines one setx per pair. It is
+//not atomic, the first error is returned.
+func (db *SSDB) MultiSetX(kvs []string, ttl time.Duration) error {
+	if len(kvs)%2 != 0 {
+		return errors.New("ssdb: odd number of arguments to MultiSetX")
+	}
+	if _, err := ttlSeconds(ttl); err != nil {
+		return err
+	}
+	p := db.Pipeline()
+	results := make([]*StatusResult, 0, len(kvs)/2)
+	for i := 0; i < len(kvs); i += 2 {
+		results = append(results, p.SetX(kvs[i], kvs[i+1], ttl))
+	}
+	if err := p.Exec(); err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r.Err() != nil {
+			return r.Err()
+		}
+	}
+	return nil
+}
+
+func (db *SSDB) SetBit(key string, offset int64, on bool) (bool, error) {
+	bit := 0
+	if on {
+		bit = 1
+	}
+	resp, err := db.do("setbit", key, offset, bit)
+	if err != nil {
+		return false, err
+	}
+	return boolReply(resp)
+}
+
+func (db *SSDB) GetBit(key string, offset int64) (bool, error) {
+	resp, err := db.do("getbit", key, offset)
+	if err != nil {
+		return false, err
+	}
+	return boolReply(resp)
+}
+
+func (db *SSDB) BitCount(key string, start, end int64) (int64, error) {
+	resp, err := db.do("bitcount", key, start, end)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(resp)
+}
+
+func (db *SSDB) CountBit(key string, start, size int64) (int64, error) {
+	resp, err := db.do("countbit", key, start, size)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(resp)
+}
+
+func (db *SSDB) Substr(key string, start, size int64) (string, error) {
+	resp, err := db.do("substr", key, start, size)
+	if err != nil {
+		return "", err
+	}
+	return StringValue(resp)
+}
+
+func (db *SSDB) StrLen(key string) (int64, error) {
+	resp, err := db.do("strlen", key)
 	if err != nil {
 		return 0, err
 	}
@@ -250,16 +600,16 @@ func (db *SSDB) Incr(key string, by int64) (int64, error) {
 }
 
 func (db *SSDB) ZSet(setname, key string, score int64) error {
-	resp, err := db.conn.Do("zset", []interface{}{setname, key, score})
-	if resp[0].String() != "ok" {
-		return errors.New(resp[0].String())
+	resp, err := db.do("zset", setname, key, score)
+	if err != nil {
+		return err
 	}
-	return err
+	return checkStatus(resp)
 }
 
 func (db *SSDB) ZGet(setname, key string) (int64, error) {
 
-	resp, err := db.conn.Do("zget", []interface{}{setname, key})
+	resp, err := db.do("zget", setname, key)
 	if err != nil {
 		return 0, err
 	}
@@ -267,7 +617,7 @@ func (db *SSDB) ZGet(setname, key string) (int64, error) {
 }
 
 func (db *SSDB) ZIncr(setname, key string, by int64) (int64, error) {
-	resp, err := db.conn.Do("zincr", []interface{}{setname, key, by})
+	resp, err := db.do("zincr", setname, key, by)
 
 	if err != nil {
 		return 0, err
@@ -277,75 +627,47 @@ func (db *SSDB) ZIncr(setname, key string, by int64) (int64, error) {
 }
 
 func (db *SSDB) ZDel(setname, key string) (bool, error) {
-	resp, err := db.conn.Do("zdel", []interface{}{setname, key})
+	resp, err := db.do("zdel", setname, key)
 	if err != nil {
 		return false, err
 	}
-	return BoolValue(resp)
+	return boolReply(resp)
 }
 
 func (db *SSDB) ZSize(setname string) (int64, error) {
-	resp, err := db.conn.Do("zsize", []interface{}{setname})
+	resp, err := db.do("zsize", setname)
 	if err != nil {
 		return 0, err
 	}
 	return Int64(resp)
 }
 
-func (db *SSDB) ZScan(setname, key_start string, score_start, score_end int64, limit int) (map[string]int64, error) {
+func (db *SSDB) ZScan(setname, key_start string, score_start, score_end int64, limit int) (ZPairs, error) {
 
-	resp, err := db.conn.Do("zscan", []interface{}{setname, key_start, score_start, score_end, limit})
+	resp, err := db.do("zscan", setname, key_start, score_start, score_end, limit)
 	if err != nil {
 		return nil, err
 	}
-	if resp[0].String() != "ok" {
-		return nil, errors.New(resp[0].String())
-	}
-
-	res := make(map[string]int64)
-	var key string
-	key = ""
-	for _, buf := range resp[1:] {
-		if key == "" {
-			key = buf.String()
-		} else {
-			res[key], _ = strconv.ParseInt(buf.String(), 10, 64)
-			key = ""
-		}
-	}
-
-	return res, nil
+	return zpairReply(resp)
 }
 
 func (db *SSDB) ZClear(setname string) error {
-	resp, err := db.conn.Do("zclear", []interface{}{setname})
+	resp, err := db.do("zclear", setname)
 	if err != nil {
 		return err
 	}
-	if resp[0].String() != "ok" {
-		return errors.New(resp[0].String())
-	}
-
-	return nil
+	return checkStatus(resp)
 }
 
 func (db *SSDB) ZList(name_start, name_end string, limit int) ([]string, error) {
-	resp, err := db.conn.Do("zlist", []interface{}{name_start, name_end, limit})
+	resp, err := db.do("zlist", name_start, name_end, limit)
 	if err != nil {
 		return nil, err
 	}
-	if resp[0].String() != "ok" {
-		return nil, errors.New(resp[0].String())
-	}
-	var res []string
-	for _, buf := range resp[1:] {
-		res = append(res, buf.String())
-	}
-
-	return res, nil
+	return StringArray(resp)
 }
 func (db *SSDB) ZCount(setname string, score_start, score_end int64) (int, error) {
-	resp, err := db.conn.Do("zcount", []interface{}{setname, score_start, score_end})
+	resp, err := db.do("zcount", setname, score_start, score_end)
 	if err != nil {
 		return 0, err
 	}
@@ -354,85 +676,152 @@ func (db *SSDB) ZCount(setname string, score_start, score_end int64) (int, error
 }
 func (db *SSDB) ZExists(setname, key string) (bool, error) {
 
-	resp, err := db.conn.Do("zexists", []interface{}{setname, key})
+	resp, err := db.do("zexists", setname, key)
 	if err != nil {
 		return false, err
 	}
-	if resp[0].String() != "ok" {
-		return false, errors.New(resp[0].String())
-	}
-	return resp[1].String() == "1", nil
+	return boolReply(resp)
 }
 
 func (db *SSDB) ZKeys(setname, key_start string, score_start, score_end int64, limit int) ([]string, error) {
 
-	resp, err := db.conn.Do("zkeys", []interface{}{setname, key_start, score_start, score_end, limit})
+	resp, err := db.do("zkeys", setname, key_start, score_start, score_end, limit)
 	if err != nil {
 		return nil, err
 	}
-	if resp[0].String() != "ok" {
-		return nil, errors.New(resp[0].String())
+	return StringArray(resp)
+}
+
+func (db *SSDB) MultiZGet(setname string, keys []string) (ZPairs, error) {
+	resp, err := db.do("multi_zget", setname, keys)
+	if err != nil {
+		return nil, err
+	}
+	return zpairReply(resp)
+}
+func (db *SSDB) MultiZset(setname string, kvs map[string]int64) error {
+	resp, err := db.do("multi_zset", setname, kvs)
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) MultiZDel(setname string, keys []string) (bool, error) {
+	resp, err := db.do("multi_zdel", setname, keys)
+	if err != nil {
+		return false, err
 	}
-	var res []string
-	for _, b := range resp[1:] {
-		res = append(res, b.String())
+	return BoolValue(resp)
+}
+
+func (db *SSDB) ZRank(setname, key string) (int64, error) {
+	resp, err := db.do("zrank", setname, key)
+	if err != nil {
+		return 0, err
 	}
-	return res, nil
+	return Int64(resp)
 }
 
-func (db *SSDB) MultiZGet(setname string, keys []string) (map[string]int64, error) {
-	var array []interface{}
-	array = append(array, setname)
-	for _, key := range keys {
-		array = append(array, key)
+func (db *SSDB) ZRrank(setname, key string) (int64, error) {
+	resp, err := db.do("zrrank", setname, key)
+	if err != nil {
+		return 0, err
 	}
-	resp, err := db.conn.Do("multi_zget", array)
+	return Int64(resp)
+}
+
+func (db *SSDB) ZRange(setname string, offset, limit int) (ZPairs, error) {
+	resp, err := db.do("zrange", setname, offset, limit)
 	if err != nil {
 		return nil, err
 	}
-	if resp[0].String() != "ok" {
-		return nil, errors.New(resp[0].String())
+	return zpairReply(resp)
+}
+
+func (db *SSDB) ZRrange(setname string, offset, limit int) (ZPairs, error) {
+	resp, err := db.do("zrrange", setname, offset, limit)
+	if err != nil {
+		return nil, err
 	}
+	return zpairReply(resp)
+}
 
-	res := make(map[string]int64)
-	key := ""
-	for _, buf := range resp[1:] {
-		if key == "" {
-			key = buf.String()
-		} else {
-			res[key], _ = strconv.ParseInt(buf.String(), 10, 64)
-			key = ""
-		}
+func (db *SSDB) ZRscan(setname, key_start string, score_start, score_end int64, limit int) (ZPairs, error) {
+	resp, err := db.do("zrscan", setname, key_start, score_start, score_end, limit)
+	if err != nil {
+		return nil, err
 	}
-	return res, nil
+	return zpairReply(resp)
 }
-func (db *SSDB) MultiZset(setname string, kvs map[string]int64) error {
-	var kva []interface{}
-	kva = append(kva, setname)
-	for k, v := range kvs {
-		kva = append(kva, k)
-		kva = append(kva, v)
+
+func (db *SSDB) ZRlist(name_start, name_end string, limit int) ([]string, error) {
+	resp, err := db.do("zrlist", name_start, name_end, limit)
+	if err != nil {
+		return nil, err
 	}
-	_, err := db.conn.Do("multi_zset", kva)
+	return StringArray(resp)
+}
+
+func (db *SSDB) ZRemRangeByRank(setname string, start, end int64) (int64, error) {
+	resp, err := db.do("zremrangebyrank", setname, start, end)
 	if err != nil {
-		return err
+		return 0, err
 	}
+	return Int64(resp)
+}
 
-	return nil
+func (db *SSDB) ZRemRangeByScore(setname string, score_start, score_end int64) (int64, error) {
+	resp, err := db.do("zremrangebyscore", setname, score_start, score_end)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(resp)
+}
+
+func (db *SSDB) ZSum(setname string, score_start, score_end int64) (int64, error) {
+	resp, err := db.do("zsum", setname, score_start, score_end)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(resp)
+}
+
+func (db *SSDB) ZAvg(setname string, score_start, score_end int64) (float64, error) {
+	resp, err := db.do("zavg", setname, score_start, score_end)
+	if err != nil {
+		return 0, err
+	}
+	return floatReply(resp)
+}
+
+func (db *SSDB) ZPopFront(setname string, limit int) (ZPairs, error) {
+	resp, err := db.do("zpop_front", setname, limit)
+	if err != nil {
+		return nil, err
+	}
+	return zpairReply(resp)
+}
+
+func (db *SSDB) ZPopBack(setname string, limit int) (ZPairs, error) {
+	resp, err := db.do("zpop_back", setname, limit)
+	if err != nil {
+		return nil, err
+	}
+	return zpairReply(resp)
 }
 
 func (db *SSDB) HSet(name, key, value string) (bool, error) {
 
-	_, err := db.conn.Do("hset", []interface{}{name, key, value})
+	resp, err := db.do("hset", name, key, value)
 	if err != nil {
 		return false, err
 	}
-
-	return true, nil
+	return boolReply(resp)
 }
 
 func (db *SSDB) HGet(name, key string) (string, error) {
-	resp, err := db.conn.Do("hget", []interface{}{name, key})
+	resp, err := db.do("hget", name, key)
 	if err != nil {
 		return "", err
 	}
@@ -441,16 +830,15 @@ func (db *SSDB) HGet(name, key string) (string, error) {
 
 func (db *SSDB) HDel(name, key string) (bool, error) {
 
-	_, err := db.conn.Do("hdel", []interface{}{name, key})
+	resp, err := db.do("hdel", name, key)
 	if err != nil {
 		return false, err
 	}
-
-	return true, nil
+	return boolReply(resp)
 }
 
 func (db *SSDB) HIncr(name, key string, by int64) (int64, error) {
-	resp, err := db.conn.Do("hincr", []interface{}{name, key, by})
+	resp, err := db.do("hincr", name, key, by)
 	if err != nil {
 		return 0, err
 	}
@@ -459,19 +847,16 @@ func (db *SSDB) HIncr(name, key string, by int64) (int64, error) {
 }
 
 func (db *SSDB) HExists(name, key string) (bool, error) {
-	resp, err := db.conn.Do("hexists", []interface{}{name, key})
+	resp, err := db.do("hexists", name, key)
 	if err != nil {
 		return false, err
 	}
-	if resp[0].String() != "ok" {
-		return false, errors.New(resp[0].String())
-	}
-	return resp[1].String() == "1", nil
+	return boolReply(resp)
 
 }
 
 func (db *SSDB) HSize(name string) (int64, error) {
-	resp, err := db.conn.Do("hsize", []interface{}{name})
+	resp, err := db.do("hsize", name)
 	if err != nil {
 		return 0, err
 	}
@@ -480,7 +865,7 @@ func (db *SSDB) HSize(name string) (int64, error) {
 }
 
 func (db *SSDB) HList(name_start, name_end string, limit int) ([]string, error) {
-	resp, err := db.conn.Do("hlist", []interface{}{name_start, name_end, limit})
+	resp, err := db.do("hlist", name_start, name_end, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -489,7 +874,7 @@ func (db *SSDB) HList(name_start, name_end string, limit int) ([]string, error)
 }
 
 func (db *SSDB) HRlist(name_start, name_end string, limit int) ([]string, error) {
-	resp, err := db.conn.Do("hrlist", []interface{}{name_start, name_end, limit})
+	resp, err := db.do("hrlist", name_start, name_end, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -498,7 +883,7 @@ func (db *SSDB) HRlist(name_start, name_end string, limit int) ([]string, error)
 }
 
 func (db *SSDB) HKeys(name, key_start, key_end string, limit int) ([]string, error) {
-	resp, err := db.conn.Do("hkeys", []interface{}{name, key_start, key_end, limit})
+	resp, err := db.do("hkeys", name, key_start, key_end, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -507,7 +892,7 @@ func (db *SSDB) HKeys(name, key_start, key_end string, limit int) ([]string, err
 }
 
 func (db *SSDB) HGetAll(name string) (map[string]string, error) {
-	resp, err := db.conn.Do("hgetall", []interface{}{name})
+	resp, err := db.do("hgetall", name)
 	if err != nil {
 		return nil, err
 	}
@@ -515,26 +900,26 @@ func (db *SSDB) HGetAll(name string) (map[string]string, error) {
 	return StringMap(resp)
 }
 
-func (db *SSDB) HScan(name, key_start, key_end string, limit int) (map[string]string, error) {
-	resp, err := db.conn.Do("hscan", []interface{}{name, key_start, key_end, limit})
+func (db *SSDB) HScan(name, key_start, key_end string, limit int) (Pairs, error) {
+	resp, err := db.do("hscan", name, key_start, key_end, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	return StringMap(resp)
+	return pairReply(resp)
 }
 
-func (db *SSDB) HRscan(name, key_start, key_end string, limit int) (map[string]string, error) {
-	resp, err := db.conn.Do("hrscan", []interface{}{name, key_start, key_end, limit})
+func (db *SSDB) HRscan(name, key_start, key_end string, limit int) (Pairs, error) {
+	resp, err := db.do("hrscan", name, key_start, key_end, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	return StringMap(resp)
+	return pairReply(resp)
 }
 
 func (db *SSDB) HClear(name string) (bool, error) {
-	resp, err := db.conn.Do("hclear", []interface{}{name})
+	resp, err := db.do("hclear", name)
 	if err != nil {
 		return false, err
 	}
@@ -542,7 +927,7 @@ func (db *SSDB) HClear(name string) (bool, error) {
 }
 
 func (db *SSDB) MultiHSet(name string, kvs []string) (bool, error) {
-	resp, err := db.conn.Do("multi_hset", []interface{}{name, kvs})
+	resp, err := db.do("multi_hset", name, kvs)
 	if err != nil {
 		return false, err
 	}
@@ -550,7 +935,7 @@ func (db *SSDB) MultiHSet(name string, kvs []string) (bool, error) {
 }
 
 func (db *SSDB) MultiHGet(name string, keys []string) (map[string]string, error) {
-	resp, err := db.conn.Do("multi_hget", []interface{}{name, keys})
+	resp, err := db.do("multi_hget", name, keys)
 	if err != nil {
 		return nil, err
 	}
@@ -559,24 +944,23 @@ func (db *SSDB) MultiHGet(name string, keys []string) (map[string]string, error)
 }
 
 func (db *SSDB) MultiHDel(name string, keys []string) (bool, error) {
-	resp, err := db.conn.Do("multi_hdel", []interface{}{name, keys})
+	resp, err := db.do("multi_hdel", name, keys)
 	if err != nil {
 		return false, err
 	}
 	return BoolValue(resp)
 }
 
-func (db *SSDB) QPushFront(name, value string) (int64, error) {
-	resp, err := db.conn.Do("qpush_front", []interface{}{name, value})
+func (db *SSDB) QPushFront(name string, items ...string) (int64, error) {
+	resp, err := db.do("qpush_front", name, items)
 	if err != nil {
-		fmt.Println("push front error")
 		return 0, err
 	}
 
 	return Int64(resp)
 }
-func (db *SSDB) QPushBack(name, value string) (int64, error) {
-	resp, err := db.conn.Do("qpush_back", []interface{}{name, value})
+func (db *SSDB) QPushBack(name string, items ...string) (int64, error) {
+	resp, err := db.do("qpush_back", name, items)
 	if err != nil {
 		return 0, err
 	}
@@ -585,7 +969,7 @@ func (db *SSDB) QPushBack(name, value string) (int64, error) {
 }
 func (db *SSDB) QPopFront(name string) (string, error) {
 
-	resp, err := db.conn.Do("qpop_front", []interface{}{name})
+	resp, err := db.do("qpop_front", name)
 	if err != nil {
 		return "", err
 	}
@@ -593,15 +977,71 @@ func (db *SSDB) QPopFront(name string) (string, error) {
 	return StringValue(resp)
 }
 func (db *SSDB) QPopBack(name string) (string, error) {
-	resp, err := db.conn.Do("qpop_back", []interface{}{name})
+	resp, err := db.do("qpop_back", name)
 	if err != nil {
 		return "", err
 	}
 
 	return StringValue(resp)
 }
+func (db *SSDB) QPopFrontN(name string, size int) ([]string, error) {
+	resp, err := db.do("qpop_front", name, size)
+	if err != nil {
+		return nil, err
+	}
+	return StringArray(resp)
+}
+
+func (db *SSDB) QPopBackN(name string, size int) ([]string, error) {
+	resp, err := db.do("qpop_back", name, size)
+	if err != nil {
+		return nil, err
+	}
+	return StringArray(resp)
+}
+
+func (db *SSDB) QTrimFront(name string, size int) (int64, error) {
+	resp, err := db.do("qtrim_front", name, size)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(resp)
+}
+
+func (db *SSDB) QTrimBack(name string, size int) (int64, error) {
+	resp, err := db.do("qtrim_back", name, size)
+	if err != nil {
+		return 0, err
+	}
+	return Int64(resp)
+}
+
+func (db *SSDB) QSet(name string, index int64, value string) error {
+	resp, err := db.do("qset", name, index, value)
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
+func (db *SSDB) QRange(name string, offset, limit int) ([]string, error) {
+	resp, err := db.do("qrange", name, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return StringArray(resp)
+}
+
+func (db *SSDB) QFix(name string) error {
+	resp, err := db.do("qfix", name)
+	if err != nil {
+		return err
+	}
+	return checkStatus(resp)
+}
+
 func (db *SSDB) QSize(name string) (int64, error) {
-	resp, err := db.conn.Do("qsize", []interface{}{name})
+	resp, err := db.do("qsize", name)
 	if err != nil {
 		return 0, err
 	}
@@ -609,7 +1049,7 @@ func (db *SSDB) QSize(name string) (int64, error) {
 	return Int64(resp)
 }
 func (db *SSDB) QList(name_start, name_end string, limit int) ([]string, error) {
-	resp, err := db.conn.Do("qlist", []interface{}{name_start, name_end, limit})
+	resp, err := db.do("qlist", name_start, name_end, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -617,21 +1057,21 @@ func (db *SSDB) QList(name_start, name_end string, limit int) ([]string, error)
 	return StringArray(resp)
 }
 func (db *SSDB) QRlist(name_start, name_end string, limit int) ([]string, error) {
-	resp, err := db.conn.Do("qrlist", []interface{}{name_start, name_end, limit})
+	resp, err := db.do("qrlist", name_start, name_end, limit)
 	if err != nil {
 		return nil, err
 	}
 	return StringArray(resp)
 }
 func (db *SSDB) QClear(name string) (bool, error) {
-	resp, err := db.conn.Do("qclear", []interface{}{name})
+	resp, err := db.do("qclear", name)
 	if err != nil {
-		return false, nil
+		return false, err
 	}
 	return BoolValue(resp)
 }
 func (db *SSDB) QFront(name string) (string, error) {
-	resp, err := db.conn.Do("qfront", []interface{}{name})
+	resp, err := db.do("qfront", name)
 	if err != nil {
 		return "", err
 	}
@@ -639,7 +1079,7 @@ func (db *SSDB) QFront(name string) (string, error) {
 	return StringValue(resp)
 }
 func (db *SSDB) QBack(name string) (string, error) {
-	resp, err := db.conn.Do("qback", []interface{}{name})
+	resp, err := db.do("qback", name)
 	if err != nil {
 		return "", err
 	}
@@ -647,7 +1087,7 @@ func (db *SSDB) QBack(name string) (string, error) {
 	return StringValue(resp)
 }
 func (db *SSDB) QGet(name string, index int64) (string, error) {
-	resp, err := db.conn.Do("qget", []interface{}{name, index})
+	resp, err := db.do("qget", name, index)
 	if err != nil {
 		return "", err
 	}
@@ -655,7 +1095,7 @@ func (db *SSDB) QGet(name string, index int64) (string, error) {
 	return StringValue(resp)
 }
 func (db *SSDB) QSlice(name string, begin, end int64) ([]string, error) {
-	resp, err := db.conn.Do("qslice", []interface{}{name, begin, end})
+	resp, err := db.do("qslice", name, begin, end)
 	if err != nil {
 		return nil, err
 	}
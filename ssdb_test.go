@@ -1,17 +1,21 @@
 package ssdb
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"github.com/jiecao-fm/ssdb/ssdbtest"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestGetSet(t *testing.T) {
-	db, err := Connect("jiecao-tucao", 8888, conn_timeout, read_timeout, write_timeout)
-	if err != nil {
-		fmt.Printf("connect to server failed:\n%v", err)
-	}
+	db := testDB(t)
 	db.Set("key1", "value1")
 	ex, _ := db.Exists("key1")
 	assert.True(t, ex, "set failed")
@@ -29,17 +33,18 @@ func TestGetSet(t *testing.T) {
 	assert.Equal(t, 4, len(m), "scan error")
 	m, _ = db.RScan("key5", "key1", 10)
 	assert.Equal(t, len(m), 4)
+	assert.Equal(t, []string{"key4", "key3", "key2", "key1"}, m.Keys(), "rscan order")
 	db.Del("lxy")
 	db.Incr("lxy", 100)
 	v, _ := db.Get("lxy")
 	assert.Equal(t, "100", v, "incr error")
 
-	_, err = db.MultiSet([]string{"k1", "v1", "k2", "v2", "k3", "v3"})
+	_, err := db.MultiSet([]string{"k1", "v1", "k2", "v2", "k3", "v3"})
 	if err != nil {
-		fmt.Printf("%v,\n")
+		fmt.Printf("%v,\n", err)
 	}
-	m, _ = db.MultiGet([]string{"k1", "k2", "k3"})
-	assert.Equal(t, 3, len(m), "multiset or multget failed")
+	mg, _ := db.MultiGet([]string{"k1", "k2", "k3"})
+	assert.Equal(t, 3, len(mg), "multiset or multget failed")
 	db.MultiDel([]string{"k1", "k2", "k3"})
 	m, _ = db.Scan("k0", "k4", 10)
 	assert.Equal(t, 0, len(m), "multidel failed")
@@ -47,24 +52,115 @@ func TestGetSet(t *testing.T) {
 
 }
 
-func TestZset(t *testing.T) {
-	db, err := Connect("jiecao-tucao", 8888, conn_timeout, read_timeout, write_timeout)
+func TestExpire(t *testing.T) {
+	srv := testServer(t)
+	db, err := Connect(srv.Host(), srv.Port(), conn_timeout, read_timeout, write_timeout)
 	if err != nil {
-		fmt.Printf("connect to server failed:\n%v", err)
+		t.Fatalf("connect to server failed: %v", err)
 	}
+	defer db.Close()
+
+	assert.Nil(t, db.SetX("tk", "v", 1500*time.Millisecond), "setx failed")
+	ttl, _ := db.TTL("tk")
+	assert.True(t, ttl > 0 && ttl <= 2*time.Second, "ttl rounded up to whole seconds, got %v", ttl)
+	srv.FastForward(2 * time.Second)
+	_, err = db.Get("tk")
+	assert.True(t, errors.Is(err, ErrNotFound), "key not expired")
+
+	db.Set("tk", "v")
+	ttl, err = db.TTL("tk")
+	assert.Nil(t, err)
+	assert.Equal(t, NoTTL, ttl, "key without ttl")
+	ttl, _ = db.TTL("missing")
+	assert.Equal(t, NoTTL, ttl, "missing key")
+	ok, _ := db.Expire("tk", time.Minute)
+	assert.True(t, ok, "expire existing key")
+	ok, _ = db.Expire("missing", time.Minute)
+	assert.False(t, ok, "expire missing key")
+	ttl, _ = db.TTL("tk")
+	assert.True(t, ttl > 58*time.Second && ttl <= time.Minute, "ttl after expire, got %v", ttl)
+
+	_, err = db.Expire("tk", 0)
+	assert.Equal(t, ErrInvalidTTL, err, "zero ttl")
+	assert.Equal(t, ErrInvalidTTL, db.SetX("tk", "v", -time.Second), "negative ttl")
+
+	ok, _ = db.SetNX("tk", "other")
+	assert.False(t, ok, "setnx existing key")
+	ok, _ = db.SetNX("nx", "v")
+	assert.True(t, ok, "setnx new key")
+	old, _ := db.GetSet("nx", "v2")
+	assert.Equal(t, "v", old, "getset old value")
+	_, err = db.GetSet("gs", "v")
+	assert.True(t, errors.Is(err, ErrNotFound), "getset missing key")
+	v, _ := db.Get("gs")
+	assert.Equal(t, "v", v, "getset stores value of a missing key")
+
+	assert.Nil(t, db.MultiSetX([]string{"m1", "a", "m2", "b"}, time.Minute), "multisetx failed")
+	ttl, _ = db.TTL("m2")
+	assert.True(t, ttl > 0, "multisetx ttl")
+	assert.NotNil(t, db.MultiSetX([]string{"m1"}, time.Minute), "odd multisetx arguments")
+	srv.FastForward(time.Minute)
+	m, _ := db.MultiGet([]string{"m1", "m2", "tk"})
+	assert.Equal(t, 0, len(m), "multisetx keys not expired")
+}
+
+func TestBits(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	old, err := db.SetBit("bits", 9, true)
+	assert.Nil(t, err)
+	assert.False(t, old, "bit was not set")
+	old, _ = db.SetBit("bits", 9, true)
+	assert.True(t, old, "bit was set")
+	db.SetBit("bits", 0, true)
+	on, _ := db.GetBit("bits", 9)
+	assert.True(t, on, "getbit")
+	on, _ = db.GetBit("bits", 100)
+	assert.False(t, on, "getbit beyond the value")
+	v, _ := db.Get("bits")
+	assert.Equal(t, "\x01\x02", v, "bits count from the least significant bit")
+	n, _ := db.BitCount("bits", 0, -1)
+	assert.Equal(t, int64(2), n, "bitcount")
+	n, _ = db.BitCount("bits", -1, -1)
+	assert.Equal(t, int64(1), n, "bitcount of the last byte")
+	n, _ = db.CountBit("bits", 1, 1)
+	assert.Equal(t, int64(1), n, "countbit")
+	old, _ = db.SetBit("bits", 9, false)
+	assert.True(t, old, "clear bit")
+	n, _ = db.BitCount("bits", 0, -1)
+	assert.Equal(t, int64(1), n, "bitcount after clear")
+	_, err = db.SetBit("bits", -1, true)
+	assert.True(t, errors.Is(err, ErrClientError), "negative offset")
+
+	db.Set("str", "hello world")
+	sub, _ := db.Substr("str", 6, 5)
+	assert.Equal(t, "world", sub, "substr")
+	sub, _ = db.Substr("str", -5, 100)
+	assert.Equal(t, "world", sub, "substr from the end")
+	sub, _ = db.Substr("str", 0, -6)
+	assert.Equal(t, "hello", sub, "substr negative size")
+	l, _ := db.StrLen("str")
+	assert.Equal(t, int64(11), l, "strlen")
+	l, _ = db.StrLen("missing")
+	assert.Equal(t, int64(0), l, "strlen of a missing key")
+}
+
+func TestZset(t *testing.T) {
+	db := testDB(t)
 
 	db.ZSet("set1", "key1", 100)
 	score, _ := db.ZGet("set1", "key1")
-	assert.Equal(t, 100, score, "zset failed")
+	assert.Equal(t, int64(100), score, "zset failed")
 
 	db.ZIncr("set1", "key1", 100)
 	score, _ = db.ZGet("set1", "key1")
-	assert.Equal(t, 200, score, "zincr failed")
+	assert.Equal(t, int64(200), score, "zincr failed")
 
 	size, _ := db.ZSize("set1")
-	assert.Equal(t, 1, size, "zsie failed")
+	assert.Equal(t, int64(1), size, "zsie failed")
 	db.ZDel("set1", "key1")
-	score, err = db.ZGet("set1", "key1")
+	score, err := db.ZGet("set1", "key1")
 	assert.NotNil(t, err, "zget failed")
 
 	var i int64
@@ -73,12 +169,15 @@ func TestZset(t *testing.T) {
 	}
 	m, _ := db.ZScan("set2", "k", int64(10), int64(15), 100)
 	assert.Equal(t, len(m), 5, "zscan failed")
+	assert.Equal(t, ZPair{"k10", 10}, m[0], "zscan order")
+	m, _ = db.ZScan("set2", "k10", 10, 15, 2)
+	assert.Equal(t, ZPairs{{"k11", 11}, {"k12", 12}}, m, "zscan page after the last key")
 
 	sets, _ := db.ZList("set", "set2", 10)
 	assert.Equal(t, 1, len(sets), "zlist failed")
 	db.ZClear("set2")
 	size, _ = db.ZSize("set2")
-	assert.Equal(t, 0, size, "zclear failed")
+	assert.Equal(t, int64(0), size, "zclear failed")
 
 	for i = 50; i < 55; i++ {
 		db.ZSet("set3", "k"+fmt.Sprintf("%d", i), i)
@@ -105,28 +204,74 @@ func TestZset(t *testing.T) {
 		fmt.Printf("%v", err)
 	}
 	assert.Nil(t, err, "multizset failed")
-	mp, _ = db.MultiZGet("set4", []string{"mk1", "mk2"})
-	assert.Equal(t, 2, len(mp), "zmultget failed")
+	zs, _ := db.MultiZGet("set4", []string{"mk1", "mk2"})
+	assert.Equal(t, mp, zs.Map(), "zmultget failed")
 	db.Close()
 
 }
 
+func TestZsetRank(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	db.MultiZset("board", map[string]int64{"a": 10, "b": 20, "c": 20, "d": 40, "e": 50})
+
+	rank, _ := db.ZRank("board", "c")
+	assert.Equal(t, int64(2), rank, "zrank")
+	rank, _ = db.ZRrank("board", "c")
+	assert.Equal(t, int64(2), rank, "zrrank")
+	rank, _ = db.ZRrank("board", "e")
+	assert.Equal(t, int64(0), rank, "zrrank of the highest score")
+	_, err := db.ZRank("board", "missing")
+	assert.True(t, errors.Is(err, ErrNotFound), "zrank of a missing key")
+
+	top, _ := db.ZRrange("board", 0, 3)
+	assert.Equal(t, ZPairs{{"e", 50}, {"d", 40}, {"c", 20}}, top, "zrrange")
+	page, _ := db.ZRange("board", 1, 2)
+	assert.Equal(t, []string{"b", "c"}, page.Keys(), "zrange")
+	rs, _ := db.ZRscan("board", "", 40, 0, 10)
+	assert.Equal(t, []string{"d", "c", "b", "a"}, rs.Keys(), "zrscan")
+	rs, _ = db.ZRscan("board", "c", 20, 0, 10)
+	assert.Equal(t, ZPairs{{"b", 20}, {"a", 10}}, rs, "zrscan after key_start")
+
+	sum, _ := db.ZSum("board", 20, 40)
+	assert.Equal(t, int64(80), sum, "zsum")
+	avg, _ := db.ZAvg("board", 10, 20)
+	assert.Equal(t, 50.0/3, avg, "zavg")
+
+	db.ZSet("board2", "x", 1)
+	names, _ := db.ZRlist("", "", 10)
+	assert.Equal(t, []string{"board2", "board"}, names, "zrlist")
+
+	n, _ := db.ZRemRangeByRank("board", 0, 1)
+	assert.Equal(t, int64(2), n, "zremrangebyrank")
+	n, _ = db.ZRemRangeByScore("board", 45, 100)
+	assert.Equal(t, int64(1), n, "zremrangebyscore")
+	left, _ := db.ZRange("board", 0, -1)
+	assert.Equal(t, map[string]int64{"c": 20, "d": 40}, left.Map(), "left after removals")
+
+	db.MultiZset("board", map[string]int64{"f": 1, "g": 99})
+	front, _ := db.ZPopFront("board", 1)
+	assert.Equal(t, ZPairs{{"f", 1}}, front, "zpop_front")
+	back, _ := db.ZPopBack("board", 2)
+	assert.Equal(t, ZPairs{{"g", 99}, {"d", 40}}, back, "zpop_back")
+	db.MultiZDel("board", []string{"c", "missing"})
+	size, _ := db.ZSize("board")
+	assert.Equal(t, int64(0), size, "multi_zdel")
+}
+
 func TestHash(t *testing.T) {
-	db, err := Connect("jiecao-tucao", 8888, conn_timeout, read_timeout, write_timeout)
-	if err != nil {
-		fmt.Printf("connect to server failed:\n%v", err)
-	}
+	db := testDB(t)
 	db.HSet("hash1", "lxy", "tiger")
 	value, _ := db.HGet("hash1", "lxy")
 	assert.Equal(t, value, "tiger", "hset or hget failed")
 	exist, _ := db.HExists("hash1", "lxy")
 	assert.True(t, exist, "hexits failed")
 	size, _ := db.HSize("hash1")
-	assert.Equal(t, 1, size, "hsize failed")
+	assert.Equal(t, int64(1), size, "hsize failed")
 	db.HDel("hash4", "lxy")
 	db.HIncr("hash4", "lxy", 1000)
 	count, _ := db.HIncr("hash4", "lxy", 0)
-	assert.Equal(t, 1000, count, "hincr failed")
+	assert.Equal(t, int64(1000), count, "hincr failed")
 	value, _ = db.HGet("hash4", "lxy")
 	assert.Equal(t, value, "1000", "hincr failed")
 	db.HSet("hash2", "lxy", "tiger")
@@ -143,35 +288,33 @@ func TestHash(t *testing.T) {
 	assert.Equal(t, 4, len(names), "hkeys failed")
 	m, _ := db.HGetAll("hash5")
 	assert.Equal(t, 5, len(m), "hgetall failed")
-	m, _ = db.HScan("hash5", "lxy0", "lxy4", 100)
-	assert.Equal(t, 4, len(m), "hscan failed")
-	m, _ = db.HRscan("hash5", "lxy4", "lxy0", 50)
-	assert.Equal(t, 4, len(m), "hrscan failed")
+	ps, _ := db.HScan("hash5", "lxy0", "lxy4", 100)
+	assert.Equal(t, 4, len(ps), "hscan failed")
+	ps, _ = db.HRscan("hash5", "lxy4", "lxy0", 50)
+	assert.Equal(t, 4, len(ps), "hrscan failed")
+	assert.Equal(t, Pair{"lxy3", "tiger"}, ps[0], "hrscan order")
 	db.HClear("hash5")
 	size, _ = db.HSize("hash5")
-	assert.Equal(t, 0, size, "hclear failed")
+	assert.Equal(t, int64(0), size, "hclear failed")
 
 	db.MultiHSet("hash6", []string{"lxy0", "v1", "lxy2", "v2", "lxy3", "v3", "lxy4", "v4"})
 	size, _ = db.HSize("hash6")
-	assert.Equal(t, size, 4, "hmultiset failed")
+	assert.Equal(t, size, int64(4), "hmultiset failed")
 	m, _ = db.MultiHGet("hash6", []string{"lxy0", "lxy3"})
 	assert.Equal(t, len(m), 2, "hmultiget failed")
-	_, err = db.MultiHDel("hash6", []string{"lxy2", "lxy3"})
+	_, err := db.MultiHDel("hash6", []string{"lxy2", "lxy3"})
 	if err != nil {
 		fmt.Printf("%v\n", err)
 	}
 	size, _ = db.HSize("hash6")
-	assert.Equal(t, size, 2, "multihdel failed")
+	assert.Equal(t, size, int64(2), "multihdel failed")
 	db.HClear("hash6")
 
 	db.Close()
 }
 
 func TestQ(t *testing.T) {
-	db, err := Connect("jiecao-tucao", 8888, conn_timeout, read_timeout, write_timeout)
-	if err != nil {
-		fmt.Printf("connect to server failed:\n%v", err)
-	}
+	db := testDB(t)
 	db.QClear("list1")
 	db.QPushFront("list1", "lxy0")
 	db.QPushBack("list1", "lxy1")
@@ -187,7 +330,7 @@ func TestQ(t *testing.T) {
 	assert.Equal(t, len(values), 3, "qslice failed")
 
 	size, _ := db.QSize("list1")
-	assert.Equal(t, 4, size, "qsize failed")
+	assert.Equal(t, int64(4), size, "qsize failed")
 	db.QPushFront("list2", "lxy0")
 	db.QPushBack("list3", "lxy0")
 	names, _ := db.QList("list1", "list3", 5)
@@ -200,3 +343,313 @@ func TestQ(t *testing.T) {
 
 	db.Close()
 }
+
+func TestQBatch(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+
+	size, _ := db.QPushBack("q", "a", "b", "c", "d", "e")
+	assert.Equal(t, int64(5), size, "qpush_back many")
+	size, _ = db.QPushFront("q", "y", "z")
+	assert.Equal(t, int64(7), size, "qpush_front many")
+	items, _ := db.QRange("q", 0, -1)
+	assert.Equal(t, []string{"z", "y", "a", "b", "c", "d", "e"}, items, "qrange all")
+	items, _ = db.QRange("q", -3, 2)
+	assert.Equal(t, []string{"c", "d"}, items, "qrange from the end")
+
+	items, _ = db.QPopFrontN("q", 2)
+	assert.Equal(t, []string{"z", "y"}, items, "qpop_front many")
+	items, _ = db.QPopBackN("q", 2)
+	assert.Equal(t, []string{"e", "d"}, items, "qpop_back many")
+
+	assert.Nil(t, db.QSet("q", -1, "C"), "qset")
+	v, _ := db.QBack("q")
+	assert.Equal(t, "C", v, "qset from the end")
+	err := db.QSet("q", 10, "x")
+	assert.True(t, errors.Is(err, ErrServerError), "qset out of range")
+
+	n, _ := db.QTrimFront("q", 1)
+	assert.Equal(t, int64(1), n, "qtrim_front")
+	n, _ = db.QTrimBack("q", 10)
+	assert.Equal(t, int64(2), n, "qtrim_back more than the size")
+	items, err = db.QPopFrontN("q", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, items, "qpop many from an empty queue")
+	assert.Nil(t, db.QFix("q"), "qfix")
+}
+
+func TestAuth(t *testing.T) {
+	srv := testServer(t)
+	srv.RequirePass("secret")
+
+	db, err := Connect(srv.Host(), srv.Port(), conn_timeout, read_timeout, write_timeout)
+	assert.Nil(t, err)
+	err = db.Set("k", "v")
+	assert.True(t, errors.Is(err, ErrNoAuth), "command without auth")
+	db.Close()
+
+	_, err = ConnectAuth(srv.Host(), srv.Port(), "wrong", conn_timeout, read_timeout, write_timeout)
+	var authErr *AuthError
+	assert.True(t, errors.As(err, &authErr), "wrong password")
+	assert.Equal(t, srv.Addr(), authErr.Addr)
+	assert.True(t, errors.Is(err, ErrServerError), "auth error unwraps to the reply status")
+
+	db, err = ConnectAuth(srv.Host(), srv.Port(), "secret", conn_timeout, read_timeout, write_timeout)
+	assert.Nil(t, err)
+	defer db.Close()
+	assert.Nil(t, db.Set("k", "v"), "command after auth")
+	db.Close()
+	assert.Nil(t, db.Connect(), "reconnect")
+	v, err := db.Get("k")
+	assert.Nil(t, err, "reconnected connection authenticated")
+	assert.Equal(t, "v", v)
+}
+
+func TestReconnect(t *testing.T) {
+	srv := testServer(t)
+	logger := &logRecorder{}
+	db, err := Dial(srv.Addr(), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer db.Close()
+	db.Set("k", "v")
+
+	srv.DropConns()
+	assert.NotNil(t, db.Set("k", "v2"), "write on a dropped connection")
+	assert.NotNil(t, db.Err(), "connection marked broken")
+	v, err := db.Get("k")
+	assert.Nil(t, err, "next command reconnects")
+	assert.Equal(t, "v", v)
+	assert.Nil(t, db.Err())
+	assert.True(t, len(logger.lines) >= 2, "failure and reconnect logged")
+
+	srv.DropConns()
+	p := db.Pipeline()
+	p.Get("k")
+	assert.NotNil(t, p.Exec(), "pipeline on a dropped connection")
+	_, err = db.WithContext(context.Background()).Get("k")
+	assert.Nil(t, err, "view reconnects")
+	assert.Nil(t, db.Err(), "views share the reconnected connection")
+
+	db.Close()
+	assert.Equal(t, ErrBroken, db.Ping(), "closed client does not reconnect")
+	assert.Nil(t, db.Connect(), "explicit connect after close")
+	assert.Nil(t, db.Ping())
+}
+
+func TestReadRetry(t *testing.T) {
+	srv := testServer(t)
+	db, err := Dial(srv.Addr(), WithReadRetry(true))
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer db.Close()
+	db.Set("k", "v")
+
+	srv.DropConns()
+	v, err := db.Get("k")
+	assert.Nil(t, err, "read retried after reconnect")
+	assert.Equal(t, "v", v)
+
+	srv.DropConns()
+	_, err = db.Incr("n", 1)
+	assert.NotNil(t, err, "write not retried")
+	n, _ := db.Get("n")
+	assert.Equal(t, "", n, "write not applied")
+
+	db2, _ := Dial(srv.Addr(), WithReadRetry(true), WithReconnect(false))
+	defer db2.Close()
+	db2.Ping()
+	srv.DropConns()
+	_, err = db2.Get("k")
+	assert.NotNil(t, err, "no retry without reconnect")
+	assert.Equal(t, ErrBroken, db2.Ping(), "no reconnect")
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	dialErr := errors.New("refused")
+	dials := 0
+	dialer := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		return nil, dialErr
+	}
+	db := NewClient("127.0.0.1:1", WithDialer(dialer), WithReconnectBackoff(time.Hour, time.Hour))
+	assert.Equal(t, dialErr, db.Ping(), "first dial")
+	err := db.Ping()
+	assert.True(t, errors.Is(err, dialErr), "backoff error wraps the dial error")
+	assert.Equal(t, 1, dials, "no dial during backoff")
+
+	o := newOptions([]Option{WithReconnectBackoff(time.Second, 5*time.Second)})
+	assert.Equal(t, time.Second, o.backoff(1))
+	assert.Equal(t, 2*time.Second, o.backoff(2))
+	assert.Equal(t, 4*time.Second, o.backoff(3))
+	assert.Equal(t, 5*time.Second, o.backoff(10), "backoff capped")
+}
+
+//starts an in-memory server and connects to it
+func testServer(t *testing.T) *ssdbtest.Server {
+	srv, err := ssdbtest.NewServer()
+	if err != nil {
+		t.Fatalf("start server failed: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return srv
+}
+
+func testDB(t *testing.T) *SSDB {
+	srv := testServer(t)
+	db, err := Connect(srv.Host(), srv.Port(), conn_timeout, read_timeout, write_timeout)
+	if err != nil {
+		t.Fatalf("connect to server failed: %v", err)
+	}
+	return db
+}
+
+//a server that accepts connections but never replies
+func silentServer(t *testing.T) (string, int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { c.Close() })
+		}
+	}()
+	addr := l.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+//a server answering every request with the blocks returned by handler
+func replyServer(t *testing.T, handler func(req []string) []string) (string, int) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	serve := func(c net.Conn) {
+		defer c.Close()
+		r := bufio.NewReader(c)
+		w := bufio.NewWriter(c)
+		for {
+			var req []string
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == "\n" {
+					break
+				}
+				size, _ := strconv.Atoi(line[:len(line)-1])
+				block := make([]byte, size+1)
+				if _, err := io.ReadFull(r, block); err != nil {
+					return
+				}
+				req = append(req, string(block[:size]))
+			}
+			for _, b := range handler(req) {
+				fmt.Fprintf(w, "%d\n%s\n", len(b), b)
+			}
+			w.WriteByte('\n')
+			if r.Buffered() == 0 {
+				w.Flush()
+			}
+		}
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(c)
+		}
+	}()
+	addr := l.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func TestWithContext(t *testing.T) {
+	host, port := silentServer(t)
+	db, err := Connect(host, port, conn_timeout, read_timeout, write_timeout)
+	if err != nil {
+		t.Fatalf("connect to server failed: %v", err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = db.WithContext(ctx).Get("key1")
+	assert.Equal(t, context.DeadlineExceeded, err, "deadline not applied")
+	assert.True(t, time.Since(start) < time.Second, "request not aborted")
+	assert.NotNil(t, db.Err(), "connection not marked broken")
+
+	db, err = Connect(host, port, conn_timeout, read_timeout, write_timeout)
+	if err != nil {
+		t.Fatalf("connect to server failed: %v", err)
+	}
+	defer db.Close()
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = db.WithContext(ctx).Get("key1")
+	assert.Equal(t, context.Canceled, err, "cancel not applied")
+	assert.NotNil(t, db.Err(), "connection not marked broken")
+}
+
+func TestWithContextKeepsResult(t *testing.T) {
+	db := testDB(t)
+	defer db.Close()
+	c := db.conn.(*conn)
+
+	//the context expires once the exchange is done but before it returns
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := c.withContext(ctx, func() error {
+		_, err := c.Do("set", []interface{}{"k", "v"})
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return err
+	})
+	assert.Nil(t, err, "completed exchange discarded")
+	assert.Nil(t, c.Err(), "connection broken after a completed exchange")
+
+	//the context expires while failing without touching the stream
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = c.withContext(ctx, func() error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return c.Send("set", []interface{}{"k", struct{}{}})
+	})
+	assert.ErrorIs(t, err, ErrInvalidArgument, "real error replaced")
+	assert.Nil(t, c.Err(), "connection broken without a read or write")
+
+	v, err := db.Get("k")
+	assert.Nil(t, err, "connection not usable afterwards")
+	assert.Equal(t, "v", v)
+}
+
+func TestReadTimeout(t *testing.T) {
+	host, port := silentServer(t)
+	db, err := Connect(host, port, conn_timeout, 50*time.Millisecond, write_timeout)
+	if err != nil {
+		t.Fatalf("connect to server failed: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Now()
+	_, err = db.Get("key1")
+	var te *TimeoutError
+	assert.True(t, errors.As(err, &te), "timeout not surfaced")
+	assert.Equal(t, "read", te.Op, "wrong timeout op")
+	assert.True(t, time.Since(start) < time.Second, "read timeout not applied")
+	assert.NotNil(t, db.Err(), "connection not marked broken")
+}
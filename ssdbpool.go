@@ -1,181 +1,475 @@
 package ssdb
 
 import (
-	"container/list"
+	"context"
 	"errors"
+	"net"
+	"strconv"
 	"sync"
 	"time"
 )
 
 const (
-	key_test       = "6ba7b814-9dad-11d1-80b4-00c04fd430c8"
-	value_test     = "1"
+	//default interval of the idle connection checker
 	check_duration = time.Minute * 5
-	incr_step      = 4
 )
 
 var (
 	conn_timeout  time.Duration = 15 * time.Second
 	read_timeout  time.Duration = 180 * time.Second
 	write_timeout time.Duration = 0
-	lock          sync.Mutex
+)
+
+var (
+	//returned by GetDB when no connection was returned within PoolConfig.WaitTimeout
+	ErrPoolTimeout = errors.New("ssdb: timed out waiting for a connection")
+	//returned by GetDB once the pool is closed
+	ErrPoolClosed = errors.New("ssdb: pool closed")
+	//returned by NewPool when neither Max_conn_count nor Initial_conn_count is positive
+	ErrPoolSize = errors.New("ssdb: pool needs a positive Max_conn_count")
 )
 
 type SSDBPool struct {
-	poolconf   PoolConfig
-	idlelist   *list.List
-	usedlist   *list.List
+	poolconf PoolConfig
+	lock     sync.Mutex
+	closed   bool
+	//closed by Close to stop the checker and wake up waiters
+	done chan struct{}
+	//idle connections in FIFO order
+	idle       chan *DBWrapper
 	idlecount  int
 	usedcount  int
 	totalcount int
+	//GetDB calls waiting for a connection, in FIFO order. A waiter is
+	//removed when it is handed a connection, or nil for a free slot to
+	//dial a new connection for, and when it gives up.
+	waiters  []chan *DBWrapper
+	counters poolCounters
 }
 type DBWrapper struct {
 	*SSDB
 	last_check_time time.Time
+	created_time    time.Time
+	//when the connection was last put back to the idle queue
+	returned_time time.Time
+	//when the connection was last handed out by GetDB
+	borrowed_time time.Time
 }
 
 type PoolConfig struct {
-	Host               string
-	Port               int
+	Host string
+	Port int
+	//sent with auth on every new connection, empty means no auth
+	Password           string
 	Initial_conn_count int
-	Max_idle_count     int
-	Max_conn_count     int
-	CheckOnGet         bool
+	//idle connections beyond this count are closed when returned, 0 means no limit
+	Max_idle_count int
+	//max number of open connections, raised to Initial_conn_count when lower,
+	//NewPool fails with ErrPoolSize when it is not positive
+	Max_conn_count int
+	//connections are checked on borrow by default, CheckOnGet keeps the
+	//check on even when SkipTestOnBorrow is set
+	CheckOnGet bool
+	//timeouts of the pooled connections, zero means the package default
+	ConnTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	//applied after the options above, e.g. WithTLS or WithDialer
+	DialOptions []Option
+	//retries commands of the pooled connections, and the dial of a new
+	//connection by GetDB, the zero value does not retry
+	Retry RetryPolicy
+	//max time GetDB waits for a connection once Max_conn_count connections
+	//are in use, 0 means wait until the context is done
+	WaitTimeout time.Duration
+
+	//checks that a connection is usable, nil means (*SSDB).Ping
+	HealthCheck func(db *SSDB) error
+	//interval of the idle connection checker, 0 means check_duration
+	CheckInterval time.Duration
+	//GetDB checks a connection before handing it out unless this is set
+	SkipTestOnBorrow bool
+	//check connections when they are returned by ReturnDB
+	TestOnReturn bool
+	//idle connections are checked every CheckInterval unless this is set
+	SkipTestWhileIdle bool
+	//connections open longer than this are closed, 0 means no limit
+	MaxLifetime time.Duration
+	//connections idle longer than this are closed, 0 means no limit
+	MaxIdleTime time.Duration
+
+	//connections returned after being borrowed longer than this are
+	//counted in PoolStats.BorrowedTooLong, 0 disables the count
+	MaxBorrowTime time.Duration
+	//receives pool events, may be nil
+	Hook PoolHook
 }
 
 func NewPool(pc PoolConfig) (*SSDBPool, error) {
-	pool := &SSDBPool{poolconf: pc}
-	pool.idlelist = list.New()
-	pool.usedlist = list.New()
+	if pc.ConnTimeout == 0 {
+		pc.ConnTimeout = conn_timeout
+	}
+	if pc.ReadTimeout == 0 {
+		pc.ReadTimeout = read_timeout
+	}
+	if pc.WriteTimeout == 0 {
+		pc.WriteTimeout = write_timeout
+	}
+	if pc.HealthCheck == nil {
+		pc.HealthCheck = (*SSDB).Ping
+	}
+	if pc.CheckInterval <= 0 {
+		pc.CheckInterval = check_duration
+	}
+	if pc.CheckOnGet {
+		pc.SkipTestOnBorrow = false
+	}
+	if pc.Max_conn_count < pc.Initial_conn_count {
+		pc.Max_conn_count = pc.Initial_conn_count
+	}
+	if pc.Max_conn_count <= 0 {
+		return nil, ErrPoolSize
+	}
+	pool := &SSDBPool{poolconf: pc, done: make(chan struct{})}
+	pool.idle = make(chan *DBWrapper, pc.Max_conn_count)
 	for i := 0; i < pc.Initial_conn_count; i++ {
-		db, err := Connect(pc.Host, pc.Port, conn_timeout, read_timeout, write_timeout)
+		dbwraper, err := pool.dial()
 		if err != nil {
+			pool.Close()
 			return pool, err
 		}
 		pool.totalcount = pool.totalcount + 1
 		pool.idlecount = pool.idlecount + 1
-		dbwraper := DBWrapper{db, time.Now()}
-		pool.idlelist.PushBack(&dbwraper)
+		pool.idle <- dbwraper
+	}
+	if !pc.SkipTestWhileIdle || pc.MaxIdleTime > 0 || pc.MaxLifetime > 0 {
+		go pool.checker()
 	}
-	go func() {
 
-		for {
+	return pool, nil
+}
 
-			t := pool.checkone()
-			t = t.Add(check_duration)
-			time.Sleep(t.Sub(time.Now()))
+func (pool *SSDBPool) dial() (*DBWrapper, error) {
+	pc := pool.poolconf
+	opts := append([]Option{WithPassword(pc.Password), WithConnTimeout(pc.ConnTimeout),
+		WithReadTimeout(pc.ReadTimeout), WithWriteTimeout(pc.WriteTimeout), WithRetryPolicy(pc.Retry)}, pc.DialOptions...)
+	db, err := Dial(net.JoinHostPort(pc.Host, strconv.Itoa(pc.Port)), opts...)
+	if err != nil {
+		return nil, err
+	}
+	pool.emit(PoolEvent{Type: EventCreate})
+	now := time.Now()
+	return &DBWrapper{SSDB: db, last_check_time: now, created_time: now, returned_time: now}, nil
+}
 
-		}
+//runs the health check and records when it passed
+func (pool *SSDBPool) check(db *DBWrapper) error {
+	err := pool.poolconf.HealthCheck(db.SSDB)
+	if err == nil {
+		db.last_check_time = time.Now()
+	} else {
+		pool.emit(PoolEvent{Type: EventHealthCheckFailure, Err: err})
+	}
+	return err
+}
 
-	}()
+//reports whether db outlived MaxLifetime or, when idle, MaxIdleTime
+func (pool *SSDBPool) expired(db *DBWrapper, now time.Time, idle bool) bool {
+	pc := pool.poolconf
+	if pc.MaxLifetime > 0 && now.Sub(db.created_time) > pc.MaxLifetime {
+		return true
+	}
+	return idle && pc.MaxIdleTime > 0 && now.Sub(db.returned_time) > pc.MaxIdleTime
+}
 
-	return pool, nil
+func (pool *SSDBPool) checker() {
+	ticker := time.NewTicker(pool.poolconf.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pool.checkIdle()
+		case <-pool.done:
+			return
+		}
+	}
 }
 
-func (pool *SSDBPool) checkone() time.Time {
-	lock.Lock()
-	defer lock.Unlock()
-	ele := pool.idlelist.Front()
-	if ele != nil {
-		db := ele.Value.(*DBWrapper)
-		t := db.last_check_time
-		err := db.Set(key_test, value_test)
-		pool.idlelist.Remove(ele)
-		if err == nil {
-			pool.idlelist.PushBack(db)
-			db.last_check_time = time.Now()
-			return t
-		} else {
-			pool.idlecount = pool.idlecount - 1
-			pool.totalcount = pool.totalcount - 1
-			return db.last_check_time.Add(-1 * check_duration)
+//walks the idle queue once, closing expired connections and, unless
+//SkipTestWhileIdle is set, those failing the health check
+func (pool *SSDBPool) checkIdle() {
+	pool.lock.Lock()
+	n := len(pool.idle)
+	pool.lock.Unlock()
+	for i := 0; i < n; i++ {
+		db, ok := pool.checkout()
+		if !ok {
+			return
 		}
+		if pool.expired(db, time.Now(), true) {
+			pool.discard(db)
+			continue
+		}
+		if !pool.poolconf.SkipTestWhileIdle && time.Since(db.last_check_time) >= pool.poolconf.CheckInterval {
+			if pool.check(db) != nil {
+				pool.discard(db)
+				continue
+			}
+		}
+		pool.put(db, db.returned_time)
+	}
+}
+
+//takes the connection at the head of the idle queue without waiting
+func (pool *SSDBPool) checkout() (*DBWrapper, bool) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	select {
+	case db := <-pool.idle:
+		pool.taken(db)
+		return db, true
+	default:
+		return nil, false
 	}
-	return time.Now()
 }
 
 func (pool *SSDBPool) GetDB() (*DBWrapper, error) {
-	defer lock.Unlock()
-	lock.Lock()
-
-	ele := pool.idlelist.Front()
-	if ele == nil {
-		if pool.totalcount < pool.poolconf.Max_conn_count {
-			left := pool.poolconf.Max_conn_count - pool.totalcount
-			if left < incr_step {
-				pool.incr(left, true)
-			} else {
-				pool.incr(incr_step, true)
+	return pool.GetDBContext(context.Background())
+}
+
+//GetDBContext returns an idle connection, dials a new one while fewer than
+//Max_conn_count are open, or else waits until one is returned, the
+//context is done or PoolConfig.WaitTimeout elapses. Waiters are served in
+//the order they started waiting.
+func (pool *SSDBPool) GetDBContext(ctx context.Context) (*DBWrapper, error) {
+	var timeout <-chan time.Time
+	if pool.poolconf.WaitTimeout > 0 {
+		timer := time.NewTimer(pool.poolconf.WaitTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		dbwraper, ok, err := pool.take(ctx, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			//a free slot, dial a new connection for it; dialing changes
+			//nothing on the server so it is retried like a read
+			err = pool.poolconf.Retry.run(ctx, ReadCommand, func() (err error) {
+				dbwraper, err = pool.dial()
+				return err
+			})
+			if err != nil {
+				pool.release()
+				return nil, err
 			}
+			pool.emit(PoolEvent{Type: EventMiss})
+			dbwraper.borrowed_time = time.Now()
+			return dbwraper, nil
 		}
+		if pool.expired(dbwraper, time.Now(), true) {
+			pool.discard(dbwraper)
+			continue
+		}
+		if !pool.poolconf.SkipTestOnBorrow && pool.check(dbwraper) != nil {
+			pool.discard(dbwraper)
+			continue
+		}
+		pool.emit(PoolEvent{Type: EventHit})
+		dbwraper.borrowed_time = time.Now()
+		return dbwraper, nil
+	}
+}
 
+//takes an idle connection, or reserves a free slot and returns ok false
+func (pool *SSDBPool) take(ctx context.Context, timeout <-chan time.Time) (db *DBWrapper, ok bool, err error) {
+	pool.lock.Lock()
+	if pool.closed {
+		pool.lock.Unlock()
+		return nil, false, ErrPoolClosed
 	}
-	ele = pool.idlelist.Front()
-	if ele == nil {
-		return nil, errors.New("can not create more client")
-	} else {
-		dbwraper := ele.Value.(*DBWrapper)
-		err := dbwraper.Set(key_test, value_test)
-		if err != nil {
-			pool.idlelist.Remove(ele)
-			pool.idlecount = pool.idlecount - 1
-			pool.totalcount = pool.totalcount - 1
+	select {
+	case db = <-pool.idle:
+		pool.taken(db)
+		pool.lock.Unlock()
+		return db, true, nil
+	default:
+	}
+	if pool.totalcount < pool.poolconf.Max_conn_count {
+		pool.totalcount = pool.totalcount + 1
+		pool.usedcount = pool.usedcount + 1
+		pool.lock.Unlock()
+		return nil, false, nil
+	}
+	//buffered so a handoff never blocks the lock holder
+	wait := make(chan *DBWrapper, 1)
+	pool.waiters = append(pool.waiters, wait)
+	pool.lock.Unlock()
 
-			return nil, errors.New("no idle conn")
+	start := time.Now()
+	select {
+	case db = <-wait:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrPoolTimeout
+	case <-pool.done:
+		err = ErrPoolClosed
+	}
+	handed := true
+	if err != nil {
+		pool.lock.Lock()
+		handed = !pool.unwait(wait)
+		pool.lock.Unlock()
+	}
+	pool.emit(PoolEvent{Type: EventWait, Duration: time.Since(start)})
+	if err != nil {
+		if handed {
+			//handed over while giving up, pass it on
+			if db = <-wait; db != nil {
+				pool.put(db, db.returned_time)
+			} else {
+				pool.release()
+			}
 		}
-		pool.usedlist.PushBack(dbwraper)
-		pool.idlecount = pool.idlecount - 1
-		pool.usedcount = pool.usedcount + 1
-		pool.idlelist.Remove(ele)
+		if err != ErrPoolClosed {
+			pool.emit(PoolEvent{Type: EventTimeout, Err: err})
+		}
+		return nil, false, err
+	}
+	return db, db != nil, nil
+}
 
-		return dbwraper, nil
+//accounts for a connection received from idle, the caller holds the lock
+func (pool *SSDBPool) taken(db *DBWrapper) {
+	pool.idlecount = pool.idlecount - 1
+	pool.usedcount = pool.usedcount + 1
+}
+
+//hands a used connection, or nil for its free slot, to the first waiter
+//and reports whether there was one, the caller holds the lock
+func (pool *SSDBPool) handoff(db *DBWrapper) bool {
+	if len(pool.waiters) == 0 || pool.closed {
+		return false
 	}
+	wait := pool.waiters[0]
+	pool.waiters = pool.waiters[1:]
+	wait <- db
+	return true
 }
 
-func (pool *SSDBPool) incr(incr_count int, heldlock bool) error {
-	if !heldlock {
-		lock.Lock()
-		defer lock.Unlock()
+//removes a waiter giving up and reports whether it was still waiting,
+//the caller holds the lock
+func (pool *SSDBPool) unwait(wait chan *DBWrapper) bool {
+	for i, w := range pool.waiters {
+		if w == wait {
+			pool.waiters = append(pool.waiters[:i], pool.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
 
+//gives up the slot of a used connection that was closed, handing it to
+//a waiter if there is one
+func (pool *SSDBPool) release() {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	if pool.handoff(nil) {
+		return
 	}
-	for i := 0; i < incr_count; i++ {
-		db, err := Connect(pool.poolconf.Host, pool.poolconf.Port, conn_timeout, read_timeout, write_timeout)
-		if err != nil {
-			return err
+	pool.usedcount = pool.usedcount - 1
+	pool.totalcount = pool.totalcount - 1
+}
+
+func (pool *SSDBPool) ReturnDB(db *DBWrapper) error {
+	if max := pool.poolconf.MaxBorrowTime; max > 0 {
+		if d := time.Since(db.borrowed_time); d > max {
+			pool.emit(PoolEvent{Type: EventBorrowedTooLong, Duration: d})
 		}
-		pool.totalcount = pool.totalcount + 1
-		pool.idlecount = pool.idlecount + 1
-		dbwraper := DBWrapper{db, time.Now()}
-		pool.idlelist.PushBack(&dbwraper)
 	}
+	if db.Err() != nil || pool.expired(db, time.Now(), false) ||
+		(pool.poolconf.TestOnReturn && pool.check(db) != nil) {
+		pool.discard(db)
+		return nil
+	}
+	pool.put(db, time.Now())
 	return nil
 }
 
-func (pool *SSDBPool) ReturnDB(db *DBWrapper) error {
+//closes a used connection and gives up its slot
+func (pool *SSDBPool) discard(db *DBWrapper) {
+	db.Close()
+	pool.emit(PoolEvent{Type: EventClose})
+	pool.release()
+}
 
-	lock.Lock()
-	defer lock.Unlock()
-	if db.Err() != nil {
+//hands a healthy used connection to the first waiter, or puts it back
+//to the idle queue
+func (pool *SSDBPool) put(db *DBWrapper, returned time.Time) {
+	db.returned_time = returned
+	pool.lock.Lock()
+	if pool.handoff(db) {
+		pool.lock.Unlock()
+		return
+	}
+	pool.usedcount = pool.usedcount - 1
+	if pool.closed ||
+		pool.poolconf.Max_idle_count > 0 && pool.idlecount >= pool.poolconf.Max_idle_count {
+		pool.totalcount = pool.totalcount - 1
+		pool.lock.Unlock()
 		db.Close()
-		pool.usedcount = pool.usedcount - 1
-	} else {
-		pool.idlelist.PushBack(db)
-		pool.idlecount = pool.idlecount + 1
-		pool.usedcount = pool.usedcount - 1
+		pool.emit(PoolEvent{Type: EventClose})
+		return
 	}
-
-	return nil
+	pool.idlecount = pool.idlecount + 1
+	pool.idle <- db
+	pool.lock.Unlock()
 }
 
 func (pool *SSDBPool) IdleCount() int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
 	return pool.idlecount
 }
 func (pool *SSDBPool) UsedCount() int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
 	return pool.usedcount
 }
 func (pool *SSDBPool) TotalCount() int {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
 	return pool.totalcount
 }
-func (pool *SSDBPool) Close() {
 
+//Close stops the health checker and closes the idle connections.
+//Connections in use are closed as they are returned, and later
+//GetDB calls fail with ErrPoolClosed.
+func (pool *SSDBPool) Close() {
+	pool.lock.Lock()
+	if pool.closed {
+		pool.lock.Unlock()
+		return
+	}
+	pool.closed = true
+	close(pool.done)
+	var dbs []*DBWrapper
+drain:
+	for {
+		select {
+		case db := <-pool.idle:
+			dbs = append(dbs, db)
+			pool.idlecount = pool.idlecount - 1
+			pool.totalcount = pool.totalcount - 1
+		default:
+			break drain
+		}
+	}
+	pool.lock.Unlock()
+	for _, db := range dbs {
+		db.Close()
+		pool.emit(PoolEvent{Type: EventClose})
+	}
 }
@@ -1,9 +1,14 @@
 package ssdb
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/stretchr/testify/assert"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestPool(t *testing.T) {
@@ -11,10 +16,11 @@ func TestPool(t *testing.T) {
 	count := 3
 	g := sync.WaitGroup{}
 	g.Add(count)
-	poolconf := PoolConfig{Host: "jiecao-tucao", Port: 8888, Initial_conn_count: 1, Max_idle_count: 3, Max_conn_count: 8}
+	srv := testServer(t)
+	poolconf := PoolConfig{Host: srv.Host(), Port: srv.Port(), Initial_conn_count: 1, Max_idle_count: 3, Max_conn_count: 8}
 	pool, err := NewPool(poolconf)
 	if err != nil {
-		return
+		t.Fatalf("create pool failed: %v", err)
 	}
 	defer pool.Close()
 
@@ -42,3 +48,415 @@ func TestPool(t *testing.T) {
 	}
 	g.Wait()
 }
+
+func TestPoolAuth(t *testing.T) {
+	srv := testServer(t)
+	srv.RequirePass("secret")
+
+	_, err := NewPool(PoolConfig{Host: srv.Host(), Port: srv.Port(), Initial_conn_count: 1, Max_conn_count: 2, Password: "wrong"})
+	var authErr *AuthError
+	assert.True(t, errors.As(err, &authErr), "pool with a wrong password")
+
+	pool, err := NewPool(PoolConfig{Host: srv.Host(), Port: srv.Port(), Initial_conn_count: 1, Max_conn_count: 2, Password: "secret"})
+	if err != nil {
+		t.Fatalf("new pool failed: %v", err)
+	}
+	defer pool.Close()
+	dbs := make([]*DBWrapper, 2)
+	for i := range dbs {
+		dbs[i], err = pool.GetDB()
+		assert.Nil(t, err)
+		assert.Nil(t, dbs[i].Set("k", "v"), "initial and new connections authenticated")
+	}
+	for _, db := range dbs {
+		pool.ReturnDB(db)
+	}
+}
+
+func okServer(t *testing.T) (string, int) {
+	return replyServer(t, func(req []string) []string {
+		return []string{"ok", "1"}
+	})
+}
+
+func TestPoolWait(t *testing.T) {
+	host, port := okServer(t)
+	poolconf := PoolConfig{Host: host, Port: port, Initial_conn_count: 1, Max_idle_count: 1, Max_conn_count: 1, WaitTimeout: 50 * time.Millisecond}
+	pool, err := NewPool(poolconf)
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	defer pool.Close()
+
+	db, err := pool.GetDB()
+	assert.Nil(t, err, "get failed")
+	_, err = pool.GetDB()
+	assert.Equal(t, ErrPoolTimeout, err, "wait timeout not applied")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = pool.GetDBContext(ctx)
+	assert.Equal(t, context.Canceled, err, "context not applied")
+
+	//waiters are served in order
+	pool.poolconf.WaitTimeout = 0
+	var order []int
+	var mu sync.Mutex
+	g := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		g.Add(1)
+		go func(i int) {
+			defer g.Done()
+			db, err := pool.GetDBContext(context.Background())
+			if !assert.Nil(t, err, "wait failed") {
+				return
+			}
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			pool.ReturnDB(db)
+		}(i)
+		//let the goroutine start waiting before the next one
+		for pool.Stats().Waiting != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	pool.ReturnDB(db)
+	g.Wait()
+	assert.Equal(t, []int{0, 1, 2}, order, "waiters not served in order")
+	assert.Equal(t, 1, pool.TotalCount(), "connections leaked")
+}
+
+func TestPoolSize(t *testing.T) {
+	host, port := okServer(t)
+	_, err := NewPool(PoolConfig{Host: host, Port: port})
+	assert.Equal(t, ErrPoolSize, err, "pool without connections")
+	_, err = NewPool(PoolConfig{Host: host, Port: port, Max_conn_count: -1})
+	assert.Equal(t, ErrPoolSize, err, "negative max conn count")
+
+	pool, err := NewPool(PoolConfig{Host: host, Port: port, Initial_conn_count: 2})
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	defer pool.Close()
+	assert.Equal(t, 2, pool.poolconf.Max_conn_count, "max conn count not raised to the initial count")
+}
+
+func TestPoolMaxIdle(t *testing.T) {
+	host, port := okServer(t)
+	poolconf := PoolConfig{Host: host, Port: port, Initial_conn_count: 0, Max_idle_count: 1, Max_conn_count: 3}
+	pool, err := NewPool(poolconf)
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	defer pool.Close()
+
+	var dbs []*DBWrapper
+	for i := 0; i < 3; i++ {
+		db, err := pool.GetDB()
+		assert.Nil(t, err, "get failed")
+		dbs = append(dbs, db)
+	}
+	assert.Equal(t, 3, pool.TotalCount(), "connections not created")
+	for _, db := range dbs {
+		pool.ReturnDB(db)
+	}
+	assert.Equal(t, 1, pool.IdleCount(), "max idle count not enforced")
+	assert.Equal(t, 1, pool.TotalCount(), "excess idle connections not closed")
+	assert.Equal(t, 0, pool.UsedCount(), "used count wrong")
+}
+
+func TestPoolClose(t *testing.T) {
+	host, port := okServer(t)
+	poolconf := PoolConfig{Host: host, Port: port, Initial_conn_count: 2, Max_idle_count: 2, Max_conn_count: 2}
+	pool, err := NewPool(poolconf)
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	db, err := pool.GetDB()
+	assert.Nil(t, err, "get failed")
+	db2, err := pool.GetDB()
+	assert.Nil(t, err, "get failed")
+	pool.ReturnDB(db2)
+
+	pool.Close()
+	pool.Close()
+	assert.NotNil(t, db2.Err(), "idle connection not closed")
+	assert.Equal(t, 1, pool.TotalCount(), "idle connection not released")
+
+	pool.ReturnDB(db)
+	assert.NotNil(t, db.Err(), "returned connection not closed")
+	assert.Equal(t, 0, pool.TotalCount(), "returned connection not released")
+
+	_, err = pool.GetDB()
+	assert.Equal(t, ErrPoolClosed, err, "get after close succeeded")
+}
+
+func TestPoolCloseWakesWaiters(t *testing.T) {
+	host, port := okServer(t)
+	poolconf := PoolConfig{Host: host, Port: port, Initial_conn_count: 1, Max_conn_count: 1}
+	pool, err := NewPool(poolconf)
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	db, err := pool.GetDB()
+	assert.Nil(t, err, "get failed")
+
+	waiter := make(chan error)
+	go func() {
+		_, err := pool.GetDB()
+		waiter <- err
+	}()
+	for pool.Stats().Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	pool.Close()
+	assert.Equal(t, ErrPoolClosed, <-waiter, "waiter not woken up")
+	pool.ReturnDB(db)
+	assert.Equal(t, 0, pool.TotalCount(), "connections leaked")
+}
+
+func TestPoolWaiterTimeout(t *testing.T) {
+	host, port := okServer(t)
+	poolconf := PoolConfig{Host: host, Port: port, Initial_conn_count: 1, Max_idle_count: 1, Max_conn_count: 1, WaitTimeout: 20 * time.Millisecond}
+	pool, err := NewPool(poolconf)
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	defer pool.Close()
+
+	db, err := pool.GetDB()
+	assert.Nil(t, err, "get failed")
+	_, err = pool.GetDB()
+	assert.Equal(t, ErrPoolTimeout, err, "wait timeout not applied")
+	db.Close()
+	pool.ReturnDB(db)
+	assert.Equal(t, 0, pool.TotalCount(), "slot handed to a waiter that gave up")
+	assert.Equal(t, 0, len(pool.idle), "free slot left in the idle queue")
+
+	//a connection discarded or returned while the waiter gives up: hold
+	//the lock so that the waiter has chosen ctx.Done but not yet taken
+	//itself off the queue when the connection is handed over
+	pool.poolconf.WaitTimeout = 0
+	for _, broken := range []bool{true, false} {
+		db, err := pool.GetDB()
+		if !assert.Nil(t, err, "get failed") {
+			return
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		waiter := make(chan error)
+		go func() {
+			_, err := pool.GetDBContext(ctx)
+			waiter <- err
+		}()
+		for pool.Stats().Waiting != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		pool.lock.Lock()
+		if broken {
+			db.Close()
+		}
+		returned := make(chan struct{})
+		go func() {
+			pool.ReturnDB(db)
+			close(returned)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		pool.lock.Unlock()
+		<-returned
+		assert.Equal(t, context.Canceled, <-waiter, "waiter not canceled")
+		stats := pool.Stats()
+		assert.Equal(t, 0, stats.Waiting, "waiter left behind")
+		assert.Equal(t, 0, stats.UsedCount, "used count wrong")
+		assert.Equal(t, stats.IdleCount, stats.TotalCount, "slot leaked")
+		assert.Equal(t, stats.IdleCount, len(pool.idle), "free slot left in the idle queue")
+	}
+	done := make(chan struct{})
+	go func() {
+		pool.checkIdle()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("checkIdle did not return")
+	}
+}
+
+func TestPoolServerGone(t *testing.T) {
+	srv := testServer(t)
+	pool, err := NewPool(PoolConfig{Host: srv.Host(), Port: srv.Port(), Initial_conn_count: 1, Max_conn_count: 1})
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	defer pool.Close()
+
+	db, err := pool.GetDB()
+	assert.Nil(t, err, "get failed")
+	srv.Close()
+	assert.NotNil(t, db.Ping(), "server gone")
+	assert.NotNil(t, db.Ping(), "reconnect refused")
+	assert.NotNil(t, db.Err(), "failed dial not reported")
+	pool.ReturnDB(db)
+	assert.Equal(t, 0, pool.IdleCount(), "client without a connection put back")
+	assert.Equal(t, 0, pool.TotalCount(), "client without a connection not discarded")
+}
+
+func TestPoolHealthCheck(t *testing.T) {
+	var mu sync.Mutex
+	var cmds []string
+	host, port := replyServer(t, func(req []string) []string {
+		mu.Lock()
+		cmds = append(cmds, req[0])
+		mu.Unlock()
+		return []string{"ok", "1"}
+	})
+	poolconf := PoolConfig{Host: host, Port: port, Initial_conn_count: 1, Max_conn_count: 2, TestOnReturn: true}
+	pool, err := NewPool(poolconf)
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	defer pool.Close()
+
+	db, err := pool.GetDB()
+	assert.Nil(t, err, "get failed")
+	pool.ReturnDB(db)
+	mu.Lock()
+	assert.Equal(t, []string{"ping", "ping"}, cmds, "health check not run on borrow and return")
+	mu.Unlock()
+
+	//a failing check discards the connection
+	fail := true
+	pool.poolconf.HealthCheck = func(db *SSDB) error {
+		if fail {
+			fail = false
+			return fmt.Errorf("unhealthy")
+		}
+		return nil
+	}
+	old := db
+	db, err = pool.GetDB()
+	assert.Nil(t, err, "get failed")
+	assert.True(t, old != db, "unhealthy connection handed out")
+	assert.NotNil(t, old.Err(), "unhealthy connection not closed")
+	assert.Equal(t, 1, pool.TotalCount(), "unhealthy connection not released")
+	pool.ReturnDB(db)
+}
+
+func TestPoolDefaultChecks(t *testing.T) {
+	var pings atomic.Int32
+	host, port := replyServer(t, func(req []string) []string {
+		if req[0] == "ping" {
+			pings.Add(1)
+		}
+		return []string{"ok", "1"}
+	})
+	poolconf := PoolConfig{Host: host, Port: port, Initial_conn_count: 1, Max_conn_count: 1, CheckInterval: 10 * time.Millisecond}
+	pool, err := NewPool(poolconf)
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	//the idle connection is checked without asking
+	deadline := time.Now().Add(time.Second)
+	for pings.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.True(t, pings.Load() > 0, "idle connection not checked")
+	pool.Close()
+
+	poolconf.SkipTestWhileIdle = true
+	pool, err = NewPool(poolconf)
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	defer pool.Close()
+	pings.Store(0)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, int32(0), pings.Load(), "idle check not skipped")
+	db, err := pool.GetDB()
+	assert.Nil(t, err, "get failed")
+	pool.ReturnDB(db)
+	assert.Equal(t, int32(1), pings.Load(), "connection not checked on borrow")
+
+	pool.poolconf.SkipTestOnBorrow = true
+	db, err = pool.GetDB()
+	assert.Nil(t, err, "get failed")
+	pool.ReturnDB(db)
+	assert.Equal(t, int32(1), pings.Load(), "borrow check not skipped")
+}
+
+func TestPoolLifetime(t *testing.T) {
+	host, port := okServer(t)
+	poolconf := PoolConfig{Host: host, Port: port, Initial_conn_count: 2, Max_conn_count: 2, MaxIdleTime: 20 * time.Millisecond, CheckInterval: 10 * time.Millisecond}
+	pool, err := NewPool(poolconf)
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	defer pool.Close()
+
+	//the checker closes connections idle for too long
+	deadline := time.Now().Add(time.Second)
+	for pool.TotalCount() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, 0, pool.TotalCount(), "idle connections not expired")
+
+	poolconf = PoolConfig{Host: host, Port: port, Max_conn_count: 1, MaxLifetime: time.Millisecond}
+	pool, err = NewPool(poolconf)
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+	defer pool.Close()
+	db, err := pool.GetDB()
+	assert.Nil(t, err, "get failed")
+	time.Sleep(2 * time.Millisecond)
+	pool.ReturnDB(db)
+	assert.NotNil(t, db.Err(), "connection past its lifetime not closed")
+	assert.Equal(t, 0, pool.TotalCount(), "connection past its lifetime not released")
+}
+
+type eventRecorder struct {
+	lock   sync.Mutex
+	events map[PoolEventType]int
+}
+
+func (r *eventRecorder) OnPoolEvent(ev PoolEvent) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.events[ev.Type]++
+}
+
+func TestPoolStats(t *testing.T) {
+	host, port := okServer(t)
+	hook := &eventRecorder{events: make(map[PoolEventType]int)}
+	poolconf := PoolConfig{Host: host, Port: port, Initial_conn_count: 1, Max_conn_count: 2, WaitTimeout: 10 * time.Millisecond, MaxBorrowTime: time.Nanosecond, Hook: hook}
+	pool, err := NewPool(poolconf)
+	if err != nil {
+		t.Fatalf("create pool failed: %v", err)
+	}
+
+	db1, _ := pool.GetDB()
+	db2, _ := pool.GetDB()
+	_, err = pool.GetDB()
+	assert.Equal(t, ErrPoolTimeout, err, "wait timeout not applied")
+	pool.ReturnDB(db1)
+	pool.ReturnDB(db2)
+	pool.Close()
+
+	stats := pool.Stats()
+	assert.Equal(t, uint64(1), stats.Hits, "hits")
+	assert.Equal(t, uint64(1), stats.Misses, "misses")
+	assert.Equal(t, uint64(1), stats.Waits, "waits")
+	assert.True(t, stats.WaitDuration >= 10*time.Millisecond, "wait duration")
+	assert.Equal(t, uint64(1), stats.Timeouts, "timeouts")
+	assert.Equal(t, uint64(2), stats.Created, "created")
+	assert.Equal(t, uint64(2), stats.Closed, "closed")
+	assert.Equal(t, uint64(2), stats.BorrowedTooLong, "borrowed too long")
+	assert.Equal(t, 0, stats.TotalCount, "total count")
+
+	hook.lock.Lock()
+	defer hook.lock.Unlock()
+	assert.Equal(t, map[PoolEventType]int{EventHit: 1, EventMiss: 1, EventWait: 1, EventTimeout: 1, EventCreate: 2, EventClose: 2, EventBorrowedTooLong: 2}, hook.events, "events not passed to the hook")
+}
@@ -0,0 +1,1311 @@
+package ssdbtest
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type command struct {
+	//number of required arguments
+	minArgs int
+	//the arguments after the required ones come in pairs
+	pairs bool
+	proc  func(s *Server, args []string) []string
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping": {0, false, func(s *Server, args []string) []string { return ok() }},
+
+		"set":       {2, false, (*Server).set},
+		"get":       {1, false, (*Server).get},
+		"del":       {1, false, (*Server).del},
+		"exists":    {1, false, (*Server).exists},
+		"incr":      {1, false, (*Server).incr},
+		"keys":      {3, false, (*Server).keys},
+		"scan":      {3, false, (*Server).scan},
+		"rscan":     {3, false, (*Server).rscan},
+		"setx":      {3, false, (*Server).setx},
+		"setnx":     {2, false, (*Server).setnx},
+		"getset":    {2, false, (*Server).getset},
+		"expire":    {2, false, (*Server).expire},
+		"ttl":       {1, false, (*Server).ttl},
+		"setbit":    {3, false, (*Server).setbit},
+		"getbit":    {2, false, (*Server).getbit},
+		"bitcount":  {1, false, (*Server).bitcount},
+		"countbit":  {1, false, (*Server).countbit},
+		"substr":    {1, false, (*Server).substr},
+		"strlen":    {1, false, (*Server).strlen},
+		"multi_set": {2, true, (*Server).multiSet},
+		"multi_get": {1, false, (*Server).multiGet},
+		"multi_del": {1, false, (*Server).multiDel},
+
+		"hset":       {3, false, (*Server).hset},
+		"hget":       {2, false, (*Server).hget},
+		"hdel":       {2, false, (*Server).hdel},
+		"hincr":      {2, false, (*Server).hincr},
+		"hexists":    {2, false, (*Server).hexists},
+		"hsize":      {1, false, (*Server).hsize},
+		"hlist":      {3, false, (*Server).hlist},
+		"hrlist":     {3, false, (*Server).hrlist},
+		"hkeys":      {4, false, (*Server).hkeys},
+		"hgetall":    {1, false, (*Server).hgetall},
+		"hscan":      {4, false, (*Server).hscan},
+		"hrscan":     {4, false, (*Server).hrscan},
+		"hclear":     {1, false, (*Server).hclear},
+		"multi_hset": {3, true, (*Server).multiHset},
+		"multi_hget": {2, false, (*Server).multiHget},
+		"multi_hdel": {2, false, (*Server).multiHdel},
+
+		"zset":       {3, false, (*Server).zset},
+		"zget":       {2, false, (*Server).zget},
+		"zdel":       {2, false, (*Server).zdel},
+		"zincr":      {2, false, (*Server).zincr},
+		"zexists":    {2, false, (*Server).zexists},
+		"zsize":      {1, false, (*Server).zsize},
+		"zlist":      {3, false, (*Server).zlist},
+		"zkeys":      {5, false, (*Server).zkeys},
+		"zscan":      {5, false, (*Server).zscan},
+		"zcount":     {3, false, (*Server).zcount},
+		"zclear":     {1, false, (*Server).zclear},
+		"multi_zset": {3, true, (*Server).multiZset},
+		"multi_zget": {2, false, (*Server).multiZget},
+		"multi_zdel": {2, false, (*Server).multiZdel},
+
+		"zrank":            {2, false, (*Server).zrank},
+		"zrrank":           {2, false, (*Server).zrrank},
+		"zrange":           {3, false, (*Server).zrangeByRank},
+		"zrrange":          {3, false, (*Server).zrrangeByRank},
+		"zrscan":           {5, false, (*Server).zrscan},
+		"zrlist":           {3, false, (*Server).zrlist},
+		"zremrangebyrank":  {3, false, (*Server).zremrangebyrank},
+		"zremrangebyscore": {3, false, (*Server).zremrangebyscore},
+		"zsum":             {3, false, (*Server).zsum},
+		"zavg":             {3, false, (*Server).zavg},
+		"zpop_front":       {2, false, (*Server).zpopFront},
+		"zpop_back":        {2, false, (*Server).zpopBack},
+
+		"qpush_front": {2, false, (*Server).qpushFront},
+		"qpush_back":  {2, false, (*Server).qpushBack},
+		"qpop_front":  {1, false, (*Server).qpopFront},
+		"qpop_back":   {1, false, (*Server).qpopBack},
+		"qsize":       {1, false, (*Server).qsize},
+		"qlist":       {3, false, (*Server).qlist},
+		"qrlist":      {3, false, (*Server).qrlist},
+		"qclear":      {1, false, (*Server).qclear},
+		"qfront":      {1, false, (*Server).qfront},
+		"qback":       {1, false, (*Server).qback},
+		"qget":        {2, false, (*Server).qget},
+		"qslice":      {3, false, (*Server).qslice},
+		"qpush":       {2, false, (*Server).qpushBack},
+		"qpop":        {1, false, (*Server).qpopFront},
+		"qtrim_front": {2, false, (*Server).qtrimFront},
+		"qtrim_back":  {2, false, (*Server).qtrimBack},
+		"qset":        {3, false, (*Server).qset},
+		"qrange":      {3, false, (*Server).qrange},
+		"qfix":        {1, false, (*Server).qfix},
+
+		"info":          {0, false, (*Server).info},
+		"dbsize":        {0, false, (*Server).dbsize},
+		"flushdb":       {0, false, (*Server).flushdb},
+		"compact":       {0, false, func(s *Server, args []string) []string { return ok() }},
+		"key_range":     {0, false, (*Server).keyRange},
+		"list_allow_ip": {0, false, (*Server).listAllowIP},
+		"add_allow_ip":  {1, false, (*Server).addAllowIP},
+		"del_allow_ip":  {1, false, (*Server).delAllowIP},
+	}
+}
+
+func ok(blocks ...string) []string {
+	return append([]string{"ok"}, blocks...)
+}
+
+func notFound() []string {
+	return []string{"not_found"}
+}
+
+func clientError(msg string) []string {
+	return []string{"client_error", msg}
+}
+
+func okInt(n int64) []string {
+	return ok(strconv.FormatInt(n, 10))
+}
+
+func okBool(b bool) []string {
+	if b {
+		return ok("1")
+	}
+	return ok("0")
+}
+
+func parseInt(s string) (int64, bool) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
+
+//parses a limit argument, a negative limit means no limit
+func parseLimit(s string) (int, bool) {
+	n, ok := parseInt(s)
+	if !ok {
+		return 0, false
+	}
+	if n < 0 || n > math.MaxInt32 {
+		return math.MaxInt32, true
+	}
+	return int(n), true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//keys with start < key <= end in ascending order, an empty end means no upper bound
+func forward(sorted []string, start, end string, limit int) []string {
+	var res []string
+	for _, k := range sorted[sort.SearchStrings(sorted, start):] {
+		if len(res) >= limit || (end != "" && k > end) {
+			break
+		}
+		if k > start {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+//keys with end <= key < start in descending order, an empty start means no upper bound
+func backward(sorted []string, start, end string, limit int) []string {
+	var res []string
+	for i := len(sorted) - 1; i >= 0; i-- {
+		k := sorted[i]
+		if start != "" && k >= start {
+			continue
+		}
+		if len(res) >= limit || k < end {
+			break
+		}
+		res = append(res, k)
+	}
+	return res
+}
+
+//parses the start, end and limit of a range command
+func rangeArgs(args []string) (start, end string, limit int, err []string) {
+	limit, valid := parseLimit(args[2])
+	if !valid {
+		return "", "", 0, clientError("invalid limit")
+	}
+	return args[0], args[1], limit, nil
+}
+
+func (s *Server) set(args []string) []string {
+	s.kv[args[0]] = args[1]
+	return ok("1")
+}
+
+func (s *Server) get(args []string) []string {
+	v, found := s.kv[args[0]]
+	if !found {
+		return notFound()
+	}
+	return ok(v)
+}
+
+func (s *Server) del(args []string) []string {
+	delete(s.kv, args[0])
+	delete(s.expires, args[0])
+	return ok("1")
+}
+
+//parses a ttl in seconds, it must be positive
+func parseTTL(arg string) (time.Duration, []string) {
+	n, valid := parseInt(arg)
+	if !valid || n <= 0 {
+		return 0, clientError("invalid exptime")
+	}
+	return time.Duration(n) * time.Second, nil
+}
+
+func (s *Server) setx(args []string) []string {
+	ttl, err := parseTTL(args[2])
+	if err != nil {
+		return err
+	}
+	s.kv[args[0]] = args[1]
+	s.expires[args[0]] = s.now().Add(ttl)
+	return ok("1")
+}
+
+func (s *Server) setnx(args []string) []string {
+	if _, found := s.kv[args[0]]; found {
+		return okBool(false)
+	}
+	s.kv[args[0]] = args[1]
+	return okBool(true)
+}
+
+func (s *Server) getset(args []string) []string {
+	v, found := s.kv[args[0]]
+	s.kv[args[0]] = args[1]
+	if !found {
+		return notFound()
+	}
+	return ok(v)
+}
+
+func (s *Server) expire(args []string) []string {
+	ttl, err := parseTTL(args[1])
+	if err != nil {
+		return err
+	}
+	if _, found := s.kv[args[0]]; !found {
+		return okBool(false)
+	}
+	s.expires[args[0]] = s.now().Add(ttl)
+	return okBool(true)
+}
+
+//remaining whole seconds, -1 for a key without ttl or a missing key
+func (s *Server) ttl(args []string) []string {
+	t, found := s.expires[args[0]]
+	if !found {
+		return okInt(-1)
+	}
+	return okInt(int64(t.Sub(s.now()) / time.Second))
+}
+
+func (s *Server) exists(args []string) []string {
+	_, found := s.kv[args[0]]
+	return okBool(found)
+}
+
+//adds by to the integer stored in v
+func incrValue(v string, found bool, arg []string) (int64, []string) {
+	by := int64(1)
+	if len(arg) > 0 {
+		n, valid := parseInt(arg[0])
+		if !valid {
+			return 0, clientError("invalid increment")
+		}
+		by = n
+	}
+	if !found {
+		return by, nil
+	}
+	n, valid := parseInt(v)
+	if !valid {
+		return 0, []string{"error", "value is not an integer or out of range"}
+	}
+	return n + by, nil
+}
+
+func (s *Server) incr(args []string) []string {
+	v, found := s.kv[args[0]]
+	n, err := incrValue(v, found, args[1:])
+	if err != nil {
+		return err
+	}
+	s.kv[args[0]] = strconv.FormatInt(n, 10)
+	return okInt(n)
+}
+
+func (s *Server) keys(args []string) []string {
+	start, end, limit, err := rangeArgs(args)
+	if err != nil {
+		return err
+	}
+	return ok(forward(sortedKeys(s.kv), start, end, limit)...)
+}
+
+func (s *Server) scan(args []string) []string {
+	start, end, limit, err := rangeArgs(args)
+	if err != nil {
+		return err
+	}
+	return ok(pairs(forward(sortedKeys(s.kv), start, end, limit), s.kv)...)
+}
+
+func (s *Server) rscan(args []string) []string {
+	start, end, limit, err := rangeArgs(args)
+	if err != nil {
+		return err
+	}
+	return ok(pairs(backward(sortedKeys(s.kv), start, end, limit), s.kv)...)
+}
+
+//flattens keys and their values in m
+func pairs(keys []string, m map[string]string) []string {
+	res := make([]string, 0, len(keys)*2)
+	for _, k := range keys {
+		res = append(res, k, m[k])
+	}
+	return res
+}
+
+//largest bit offset SSDB accepts
+const max_bit_offset = 1<<30 - 1
+
+func (s *Server) setbit(args []string) []string {
+	offset, valid := parseInt(args[1])
+	if !valid || offset < 0 || offset > max_bit_offset {
+		return clientError("offset is out of range [0, 1073741823]")
+	}
+	bit, valid := parseInt(args[2])
+	if !valid || (bit != 0 && bit != 1) {
+		return clientError("bit is not 0 or 1")
+	}
+	v := []byte(s.kv[args[0]])
+	if n := int(offset/8) + 1; n > len(v) {
+		v = append(v, make([]byte, n-len(v))...)
+	}
+	mask := byte(1) << (offset % 8)
+	old := v[offset/8]&mask != 0
+	if bit == 1 {
+		v[offset/8] |= mask
+	} else {
+		v[offset/8] &^= mask
+	}
+	s.kv[args[0]] = string(v)
+	return okBool(old)
+}
+
+func (s *Server) getbit(args []string) []string {
+	offset, valid := parseInt(args[1])
+	if !valid || offset < 0 || offset > max_bit_offset {
+		return clientError("offset is out of range [0, 1073741823]")
+	}
+	v := s.kv[args[0]]
+	if offset/8 >= int64(len(v)) {
+		return okBool(false)
+	}
+	return okBool(v[offset/8]&(byte(1)<<(offset%8)) != 0)
+}
+
+//parses the optional integer arguments of bitcount, countbit and substr
+func optInts(args []string, defaults ...int64) ([]int64, []string) {
+	res := append([]int64(nil), defaults...)
+	for i := range res {
+		if i < len(args) {
+			n, valid := parseInt(args[i])
+			if !valid {
+				return nil, clientError("invalid argument")
+			}
+			res[i] = n
+		}
+	}
+	return res, nil
+}
+
+//bytes start<=index<=end of str, negative indexes count from the end
+func slice(str string, start, end int64) string {
+	n := int64(len(str))
+	if start < 0 {
+		start += n
+	}
+	if end < 0 {
+		end += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= n {
+		end = n - 1
+	}
+	if start > end {
+		return ""
+	}
+	return str[start : end+1]
+}
+
+//size bytes from start, a negative size leaves out that many bytes at the end
+func substr(str string, start, size int64) string {
+	n := int64(len(str))
+	if start < 0 {
+		start += n
+	}
+	if size < 0 {
+		size = n + size - start
+	}
+	if start < 0 || size < 0 || start >= n {
+		return ""
+	}
+	if start+size > n {
+		size = n - start
+	}
+	return str[start : start+size]
+}
+
+func countBits(str string) int64 {
+	var n int
+	for i := 0; i < len(str); i++ {
+		n += bits.OnesCount8(str[i])
+	}
+	return int64(n)
+}
+
+func (s *Server) bitcount(args []string) []string {
+	n, err := optInts(args[1:], 0, -1)
+	if err != nil {
+		return err
+	}
+	return okInt(countBits(slice(s.kv[args[0]], n[0], n[1])))
+}
+
+func (s *Server) countbit(args []string) []string {
+	n, err := optInts(args[1:], 0, math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	return okInt(countBits(substr(s.kv[args[0]], n[0], n[1])))
+}
+
+func (s *Server) substr(args []string) []string {
+	n, err := optInts(args[1:], 0, math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	return ok(substr(s.kv[args[0]], n[0], n[1]))
+}
+
+func (s *Server) strlen(args []string) []string {
+	return okInt(int64(len(s.kv[args[0]])))
+}
+
+func (s *Server) multiSet(args []string) []string {
+	for i := 0; i < len(args); i += 2 {
+		s.kv[args[i]] = args[i+1]
+	}
+	return okInt(int64(len(args) / 2))
+}
+
+func (s *Server) multiGet(args []string) []string {
+	res := ok()
+	for _, k := range args {
+		if v, found := s.kv[k]; found {
+			res = append(res, k, v)
+		}
+	}
+	return res
+}
+
+func (s *Server) multiDel(args []string) []string {
+	for _, k := range args {
+		delete(s.kv, k)
+		delete(s.expires, k)
+	}
+	return okInt(int64(len(args)))
+}
+
+func (s *Server) hset(args []string) []string {
+	h := s.hashes[args[0]]
+	if h == nil {
+		h = make(map[string]string)
+		s.hashes[args[0]] = h
+	}
+	_, found := h[args[1]]
+	h[args[1]] = args[2]
+	return okBool(!found)
+}
+
+func (s *Server) hget(args []string) []string {
+	v, found := s.hashes[args[0]][args[1]]
+	if !found {
+		return notFound()
+	}
+	return ok(v)
+}
+
+func (s *Server) hdel(args []string) []string {
+	h := s.hashes[args[0]]
+	_, found := h[args[1]]
+	delete(h, args[1])
+	if len(h) == 0 {
+		delete(s.hashes, args[0])
+	}
+	return okBool(found)
+}
+
+func (s *Server) hincr(args []string) []string {
+	v, found := s.hashes[args[0]][args[1]]
+	n, err := incrValue(v, found, args[2:])
+	if err != nil {
+		return err
+	}
+	h := s.hashes[args[0]]
+	if h == nil {
+		h = make(map[string]string)
+		s.hashes[args[0]] = h
+	}
+	h[args[1]] = strconv.FormatInt(n, 10)
+	return okInt(n)
+}
+
+func (s *Server) hexists(args []string) []string {
+	_, found := s.hashes[args[0]][args[1]]
+	return okBool(found)
+}
+
+func (s *Server) hsize(args []string) []string {
+	return okInt(int64(len(s.hashes[args[0]])))
+}
+
+func (s *Server) hlist(args []string) []string {
+	start, end, limit, err := rangeArgs(args)
+	if err != nil {
+		return err
+	}
+	return ok(forward(sortedKeys(s.hashes), start, end, limit)...)
+}
+
+func (s *Server) hrlist(args []string) []string {
+	start, end, limit, err := rangeArgs(args)
+	if err != nil {
+		return err
+	}
+	return ok(backward(sortedKeys(s.hashes), start, end, limit)...)
+}
+
+func (s *Server) hkeys(args []string) []string {
+	start, end, limit, err := rangeArgs(args[1:])
+	if err != nil {
+		return err
+	}
+	return ok(forward(sortedKeys(s.hashes[args[0]]), start, end, limit)...)
+}
+
+func (s *Server) hgetall(args []string) []string {
+	h := s.hashes[args[0]]
+	return ok(pairs(sortedKeys(h), h)...)
+}
+
+func (s *Server) hscan(args []string) []string {
+	start, end, limit, err := rangeArgs(args[1:])
+	if err != nil {
+		return err
+	}
+	h := s.hashes[args[0]]
+	return ok(pairs(forward(sortedKeys(h), start, end, limit), h)...)
+}
+
+func (s *Server) hrscan(args []string) []string {
+	start, end, limit, err := rangeArgs(args[1:])
+	if err != nil {
+		return err
+	}
+	h := s.hashes[args[0]]
+	return ok(pairs(backward(sortedKeys(h), start, end, limit), h)...)
+}
+
+func (s *Server) hclear(args []string) []string {
+	n := len(s.hashes[args[0]])
+	delete(s.hashes, args[0])
+	return okInt(int64(n))
+}
+
+func (s *Server) multiHset(args []string) []string {
+	var n int64
+	for i := 1; i < len(args); i += 2 {
+		if s.hset([]string{args[0], args[i], args[i+1]})[1] == "1" {
+			n++
+		}
+	}
+	return okInt(n)
+}
+
+func (s *Server) multiHget(args []string) []string {
+	h := s.hashes[args[0]]
+	res := ok()
+	for _, k := range args[1:] {
+		if v, found := h[k]; found {
+			res = append(res, k, v)
+		}
+	}
+	return res
+}
+
+func (s *Server) multiHdel(args []string) []string {
+	var n int64
+	for _, k := range args[1:] {
+		if s.hdel([]string{args[0], k})[1] == "1" {
+			n++
+		}
+	}
+	return okInt(n)
+}
+
+type zitem struct {
+	key   string
+	score int64
+}
+
+//items of a sorted set ordered by score, then key
+func zitems(z map[string]int64) []zitem {
+	items := make([]zitem, 0, len(z))
+	for k, v := range z {
+		items = append(items, zitem{k, v})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].score != items[j].score {
+			return items[i].score < items[j].score
+		}
+		return items[i].key < items[j].key
+	})
+	return items
+}
+
+//parses a score bound, an empty bound is the given default
+func parseScore(s string, def int64) (int64, bool) {
+	if s == "" {
+		return def, true
+	}
+	return parseInt(s)
+}
+
+//items after (key_start, score_start) up to score_end, in ascending order
+//or in descending order if reverse is set
+func (s *Server) zrange(args []string, reverse bool) ([]zitem, []string) {
+	min, max := int64(math.MinInt64), int64(math.MaxInt64)
+	if reverse {
+		min, max = max, min
+	}
+	keyStart := args[1]
+	scoreStart, valid := parseScore(args[2], min)
+	if !valid {
+		return nil, clientError("invalid score")
+	}
+	scoreEnd, valid := parseScore(args[3], max)
+	if !valid {
+		return nil, clientError("invalid score")
+	}
+	limit, valid := parseLimit(args[4])
+	if !valid {
+		return nil, clientError("invalid limit")
+	}
+	items := zitems(s.zsets[args[0]])
+	//before reports whether a precedes b in the scan order
+	before := func(a, b int64) bool { return a < b }
+	if reverse {
+		items = reversed(items)
+		before = func(a, b int64) bool { return a > b }
+	}
+	var res []zitem
+	for _, it := range items {
+		if len(res) >= limit || before(scoreEnd, it.score) {
+			break
+		}
+		if before(it.score, scoreStart) {
+			continue
+		}
+		if keyStart != "" && it.score == scoreStart {
+			if (!reverse && it.key <= keyStart) || (reverse && it.key >= keyStart) {
+				continue
+			}
+		}
+		res = append(res, it)
+	}
+	return res, nil
+}
+
+func (s *Server) zset(args []string) []string {
+	score, valid := parseInt(args[2])
+	if !valid {
+		return clientError("invalid score")
+	}
+	z := s.zsets[args[0]]
+	if z == nil {
+		z = make(map[string]int64)
+		s.zsets[args[0]] = z
+	}
+	_, found := z[args[1]]
+	z[args[1]] = score
+	return okBool(!found)
+}
+
+func (s *Server) zget(args []string) []string {
+	v, found := s.zsets[args[0]][args[1]]
+	if !found {
+		return notFound()
+	}
+	return okInt(v)
+}
+
+func (s *Server) zdel(args []string) []string {
+	z := s.zsets[args[0]]
+	_, found := z[args[1]]
+	delete(z, args[1])
+	if len(z) == 0 {
+		delete(s.zsets, args[0])
+	}
+	return okBool(found)
+}
+
+func (s *Server) zincr(args []string) []string {
+	v, found := s.zsets[args[0]][args[1]]
+	n, err := incrValue(strconv.FormatInt(v, 10), found, args[2:])
+	if err != nil {
+		return err
+	}
+	z := s.zsets[args[0]]
+	if z == nil {
+		z = make(map[string]int64)
+		s.zsets[args[0]] = z
+	}
+	z[args[1]] = n
+	return okInt(n)
+}
+
+func (s *Server) zexists(args []string) []string {
+	_, found := s.zsets[args[0]][args[1]]
+	return okBool(found)
+}
+
+func (s *Server) zsize(args []string) []string {
+	return okInt(int64(len(s.zsets[args[0]])))
+}
+
+func (s *Server) zlist(args []string) []string {
+	start, end, limit, err := rangeArgs(args)
+	if err != nil {
+		return err
+	}
+	return ok(forward(sortedKeys(s.zsets), start, end, limit)...)
+}
+
+func (s *Server) zkeys(args []string) []string {
+	items, err := s.zrange(args, false)
+	if err != nil {
+		return err
+	}
+	res := ok()
+	for _, it := range items {
+		res = append(res, it.key)
+	}
+	return res
+}
+
+func (s *Server) zscan(args []string) []string {
+	items, err := s.zrange(args, false)
+	if err != nil {
+		return err
+	}
+	return ok(zpairs(items)...)
+}
+
+//flattens items into key/score pairs
+func zpairs(items []zitem) []string {
+	res := make([]string, 0, len(items)*2)
+	for _, it := range items {
+		res = append(res, it.key, strconv.FormatInt(it.score, 10))
+	}
+	return res
+}
+
+func (s *Server) zcount(args []string) []string {
+	items, err := s.byScore(args)
+	if err != nil {
+		return err
+	}
+	return okInt(int64(len(items)))
+}
+
+func (s *Server) zclear(args []string) []string {
+	n := len(s.zsets[args[0]])
+	delete(s.zsets, args[0])
+	return okInt(int64(n))
+}
+
+func (s *Server) multiZset(args []string) []string {
+	var n int64
+	for i := 1; i < len(args); i += 2 {
+		res := s.zset([]string{args[0], args[i], args[i+1]})
+		if res[0] != "ok" {
+			return res
+		}
+		if res[1] == "1" {
+			n++
+		}
+	}
+	return okInt(n)
+}
+
+func (s *Server) multiZget(args []string) []string {
+	z := s.zsets[args[0]]
+	res := ok()
+	for _, k := range args[1:] {
+		if v, found := z[k]; found {
+			res = append(res, k, strconv.FormatInt(v, 10))
+		}
+	}
+	return res
+}
+
+func (s *Server) multiZdel(args []string) []string {
+	var n int64
+	for _, k := range args[1:] {
+		if s.zdel([]string{args[0], k})[1] == "1" {
+			n++
+		}
+	}
+	return okInt(n)
+}
+
+//reverses items in place
+func reversed(items []zitem) []zitem {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	return items
+}
+
+func (s *Server) rank(args []string, reverse bool) []string {
+	items := zitems(s.zsets[args[0]])
+	if reverse {
+		items = reversed(items)
+	}
+	for i, it := range items {
+		if it.key == args[1] {
+			return okInt(int64(i))
+		}
+	}
+	return notFound()
+}
+
+func (s *Server) zrank(args []string) []string {
+	return s.rank(args, false)
+}
+
+func (s *Server) zrrank(args []string) []string {
+	return s.rank(args, true)
+}
+
+//limit items from rank offset
+func (s *Server) byRank(args []string, reverse bool) ([]zitem, []string) {
+	offset, valid := parseInt(args[1])
+	if !valid || offset < 0 {
+		return nil, clientError("invalid offset")
+	}
+	limit, valid := parseLimit(args[2])
+	if !valid {
+		return nil, clientError("invalid limit")
+	}
+	items := zitems(s.zsets[args[0]])
+	if reverse {
+		items = reversed(items)
+	}
+	if offset > int64(len(items)) {
+		offset = int64(len(items))
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+func (s *Server) zrangeByRank(args []string) []string {
+	items, err := s.byRank(args, false)
+	if err != nil {
+		return err
+	}
+	return ok(zpairs(items)...)
+}
+
+func (s *Server) zrrangeByRank(args []string) []string {
+	items, err := s.byRank(args, true)
+	if err != nil {
+		return err
+	}
+	return ok(zpairs(items)...)
+}
+
+func (s *Server) zrscan(args []string) []string {
+	items, err := s.zrange(args, true)
+	if err != nil {
+		return err
+	}
+	return ok(zpairs(items)...)
+}
+
+func (s *Server) zrlist(args []string) []string {
+	start, end, limit, err := rangeArgs(args)
+	if err != nil {
+		return err
+	}
+	return ok(backward(sortedKeys(s.zsets), start, end, limit)...)
+}
+
+//deletes items from setname and returns how many were deleted
+func (s *Server) zremove(setname string, items []zitem) []string {
+	for _, it := range items {
+		s.zdel([]string{setname, it.key})
+	}
+	return okInt(int64(len(items)))
+}
+
+func (s *Server) zremrangebyrank(args []string) []string {
+	start, valid := parseInt(args[1])
+	if !valid || start < 0 {
+		return clientError("invalid start")
+	}
+	end, valid := parseInt(args[2])
+	if !valid {
+		return clientError("invalid end")
+	}
+	if end < start {
+		return okInt(0)
+	}
+	items, err := s.byRank([]string{args[0], args[1], strconv.FormatInt(end-start+1, 10)}, false)
+	if err != nil {
+		return err
+	}
+	return s.zremove(args[0], items)
+}
+
+//items with score_start <= score <= score_end
+func (s *Server) byScore(args []string) ([]zitem, []string) {
+	return s.zrange([]string{args[0], "", args[1], args[2], "-1"}, false)
+}
+
+func (s *Server) zremrangebyscore(args []string) []string {
+	items, err := s.byScore(args)
+	if err != nil {
+		return err
+	}
+	return s.zremove(args[0], items)
+}
+
+func (s *Server) zsum(args []string) []string {
+	items, err := s.byScore(args)
+	if err != nil {
+		return err
+	}
+	var sum int64
+	for _, it := range items {
+		sum += it.score
+	}
+	return okInt(sum)
+}
+
+func (s *Server) zavg(args []string) []string {
+	items, err := s.byScore(args)
+	if err != nil {
+		return err
+	}
+	var avg float64
+	if len(items) > 0 {
+		var sum int64
+		for _, it := range items {
+			sum += it.score
+		}
+		avg = float64(sum) / float64(len(items))
+	}
+	return ok(strconv.FormatFloat(avg, 'f', -1, 64))
+}
+
+func (s *Server) zpop(args []string, reverse bool) []string {
+	items, err := s.byRank([]string{args[0], "0", args[1]}, reverse)
+	if err != nil {
+		return err
+	}
+	s.zremove(args[0], items)
+	return ok(zpairs(items)...)
+}
+
+func (s *Server) zpopFront(args []string) []string {
+	return s.zpop(args, false)
+}
+
+func (s *Server) zpopBack(args []string) []string {
+	return s.zpop(args, true)
+}
+
+func (s *Server) qpushFront(args []string) []string {
+	q := s.queues[args[0]]
+	for _, v := range args[1:] {
+		q = append([]string{v}, q...)
+	}
+	s.queues[args[0]] = q
+	return okInt(int64(len(q)))
+}
+
+func (s *Server) qpushBack(args []string) []string {
+	q := append(s.queues[args[0]], args[1:]...)
+	s.queues[args[0]] = q
+	return okInt(int64(len(q)))
+}
+
+//stores q under name, dropping the queue once empty
+func (s *Server) setQueue(name string, q []string) {
+	if len(q) == 0 {
+		delete(s.queues, name)
+	} else {
+		s.queues[name] = q
+	}
+}
+
+//removes up to size items from the front or the back, in the order popped
+func (s *Server) qremove(name string, size int64, back bool) []string {
+	q := s.queues[name]
+	if size > int64(len(q)) {
+		size = int64(len(q))
+	}
+	items := make([]string, 0, size)
+	if back {
+		for i := int64(1); i <= size; i++ {
+			items = append(items, q[int64(len(q))-i])
+		}
+		s.setQueue(name, q[:int64(len(q))-size])
+	} else {
+		items = append(items, q[:size]...)
+		s.setQueue(name, q[size:])
+	}
+	return items
+}
+
+//pops one item, or a list of up to size items when size is given
+func (s *Server) qpop(args []string, back bool) []string {
+	if len(args) == 1 {
+		items := s.qremove(args[0], 1, back)
+		if len(items) == 0 {
+			return notFound()
+		}
+		return ok(items[0])
+	}
+	size, valid := parseInt(args[1])
+	if !valid || size < 0 {
+		return clientError("invalid size")
+	}
+	return ok(s.qremove(args[0], size, back)...)
+}
+
+func (s *Server) qpopFront(args []string) []string {
+	return s.qpop(args, false)
+}
+
+func (s *Server) qpopBack(args []string) []string {
+	return s.qpop(args, true)
+}
+
+func (s *Server) qtrim(args []string, back bool) []string {
+	size, valid := parseInt(args[1])
+	if !valid || size < 0 {
+		return clientError("invalid size")
+	}
+	return okInt(int64(len(s.qremove(args[0], size, back))))
+}
+
+func (s *Server) qtrimFront(args []string) []string {
+	return s.qtrim(args, false)
+}
+
+func (s *Server) qtrimBack(args []string) []string {
+	return s.qtrim(args, true)
+}
+
+func (s *Server) qsize(args []string) []string {
+	return okInt(int64(len(s.queues[args[0]])))
+}
+
+func (s *Server) qlist(args []string) []string {
+	start, end, limit, err := rangeArgs(args)
+	if err != nil {
+		return err
+	}
+	return ok(forward(sortedKeys(s.queues), start, end, limit)...)
+}
+
+func (s *Server) qrlist(args []string) []string {
+	start, end, limit, err := rangeArgs(args)
+	if err != nil {
+		return err
+	}
+	return ok(backward(sortedKeys(s.queues), start, end, limit)...)
+}
+
+func (s *Server) qclear(args []string) []string {
+	n := len(s.queues[args[0]])
+	delete(s.queues, args[0])
+	return okInt(int64(n))
+}
+
+func (s *Server) qfront(args []string) []string {
+	return s.qget([]string{args[0], "0"})
+}
+
+func (s *Server) qback(args []string) []string {
+	return s.qget([]string{args[0], "-1"})
+}
+
+//resolves a possibly negative queue index
+func index(n int64, size int) int64 {
+	if n < 0 {
+		n += int64(size)
+	}
+	return n
+}
+
+func (s *Server) qget(args []string) []string {
+	n, valid := parseInt(args[1])
+	if !valid {
+		return clientError("invalid index")
+	}
+	q := s.queues[args[0]]
+	n = index(n, len(q))
+	if n < 0 || n >= int64(len(q)) {
+		return notFound()
+	}
+	return ok(q[n])
+}
+
+func (s *Server) qset(args []string) []string {
+	n, valid := parseInt(args[1])
+	if !valid {
+		return clientError("invalid index")
+	}
+	q := s.queues[args[0]]
+	n = index(n, len(q))
+	if n < 0 || n >= int64(len(q)) {
+		return []string{"error", "index out of range"}
+	}
+	q[n] = args[2]
+	return ok()
+}
+
+func (s *Server) qrange(args []string) []string {
+	offset, valid := parseInt(args[1])
+	if !valid {
+		return clientError("invalid offset")
+	}
+	limit, valid := parseLimit(args[2])
+	if !valid {
+		return clientError("invalid limit")
+	}
+	q := s.queues[args[0]]
+	begin := index(offset, len(q))
+	if begin < 0 {
+		begin = 0
+	}
+	if begin >= int64(len(q)) {
+		return ok()
+	}
+	end := int64(len(q))
+	if begin+int64(limit) < end {
+		end = begin + int64(limit)
+	}
+	return ok(q[begin:end]...)
+}
+
+//the queues here cannot get out of sync
+func (s *Server) qfix(args []string) []string {
+	return ok()
+}
+
+func (s *Server) qslice(args []string) []string {
+	begin, valid := parseInt(args[1])
+	if !valid {
+		return clientError("invalid index")
+	}
+	end, valid := parseInt(args[2])
+	if !valid {
+		return clientError("invalid index")
+	}
+	q := s.queues[args[0]]
+	begin = index(begin, len(q))
+	end = index(end, len(q))
+	if begin < 0 {
+		begin = 0
+	}
+	if end >= int64(len(q)) {
+		end = int64(len(q)) - 1
+	}
+	if begin > end {
+		return ok()
+	}
+	return ok(q[begin : end+1]...)
+}
+
+//the version reported by info
+const Version = "ssdbtest"
+
+func (s *Server) info(args []string) []string {
+	return ok("ssdb-server",
+		"version", Version,
+		"links", strconv.Itoa(len(s.conns)),
+		"total_calls", strconv.FormatInt(s.calls, 10),
+		"dbsize", strconv.FormatInt(s.size(), 10),
+		"binlogs", "    capacity: 0\n    min_seq: 0\n    max_seq: 0",
+	)
+}
+
+//bytes of all keys and values, standing in for the size on disk
+func (s *Server) size() int64 {
+	var n int
+	for k, v := range s.kv {
+		n += len(k) + len(v)
+	}
+	for name, h := range s.hashes {
+		for k, v := range h {
+			n += len(name) + len(k) + len(v)
+		}
+	}
+	for name, z := range s.zsets {
+		for k := range z {
+			n += len(name) + len(k) + 8
+		}
+	}
+	for name, q := range s.queues {
+		for _, v := range q {
+			n += len(name) + len(v) + 8
+		}
+	}
+	return int64(n)
+}
+
+func (s *Server) dbsize(args []string) []string {
+	return okInt(s.size())
+}
+
+func (s *Server) flushdb(args []string) []string {
+	s.reset()
+	return ok()
+}
+
+//first and last name of a sorted key list, empty if there is none
+func bounds(sorted []string) []string {
+	if len(sorted) == 0 {
+		return []string{"", ""}
+	}
+	return []string{sorted[0], sorted[len(sorted)-1]}
+}
+
+func (s *Server) keyRange(args []string) []string {
+	res := ok(bounds(sortedKeys(s.kv))...)
+	res = append(res, bounds(sortedKeys(s.hashes))...)
+	res = append(res, bounds(sortedKeys(s.zsets))...)
+	return append(res, bounds(sortedKeys(s.queues))...)
+}
+
+func (s *Server) listAllowIP(args []string) []string {
+	return ok(sortedKeys(s.allowIPs)...)
+}
+
+func (s *Server) addAllowIP(args []string) []string {
+	s.allowIPs[args[0]] = struct{}{}
+	return ok()
+}
+
+func (s *Server) delAllowIP(args []string) []string {
+	delete(s.allowIPs, args[0])
+	return ok()
+}
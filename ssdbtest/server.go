@@ -0,0 +1,289 @@
+//Package ssdbtest provides an in-memory server speaking the SSDB protocol,
+//for hermetic tests of code using the ssdb client.
+//
+//	srv, err := ssdbtest.NewServer()
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer srv.Close()
+//	db, err := ssdb.Connect(srv.Host(), srv.Port(), time.Second, time.Second, 0)
+//
+//The server implements the KV, hash, sorted set, queue and admin commands with
+//the reply statuses and range boundaries of a real SSDB server. Data lives
+//in memory and is shared by all connections.
+package ssdbtest
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Server struct {
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	lock   sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+	//commands executed, for info
+	calls    int64
+	allowIPs map[string]struct{}
+	//required by auth when not empty
+	password string
+
+	//guarded by lock
+	kv      map[string]string
+	expires map[string]time.Time
+	//added to the wall clock, see FastForward
+	offset time.Duration
+	hashes map[string]map[string]string
+	zsets  map[string]map[string]int64
+	queues map[string][]string
+}
+
+//NewServer starts a server listening on a loopback port
+func NewServer() (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return start(l), nil
+}
+
+//NewTLSServer is NewServer for clients connecting with TLS, config must
+//hold a certificate
+func NewTLSServer(config *tls.Config) (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return start(tls.NewListener(l, config)), nil
+}
+
+func start(l net.Listener) *Server {
+	s := &Server{listener: l, conns: make(map[net.Conn]struct{}), allowIPs: make(map[string]struct{})}
+	s.reset()
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+//Addr returns the host:port the server listens on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *Server) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+//Reset drops all data
+func (s *Server) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reset()
+}
+
+//FastForward moves the server clock forward by d, expiring the keys
+//whose ttl ran out, so tests need not sleep
+func (s *Server) FastForward(d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.offset += d
+}
+
+//RequirePass makes the server reject commands with status "noauth"
+//until a connection sends auth with password, an empty password turns
+//authentication off. Connections already authenticated stay so.
+func (s *Server) RequirePass(password string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.password = password
+}
+
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+//drops the keys whose ttl ran out
+func (s *Server) purge() {
+	now := s.now()
+	for k, t := range s.expires {
+		if !now.Before(t) {
+			delete(s.kv, k)
+			delete(s.expires, k)
+		}
+	}
+}
+
+func (s *Server) reset() {
+	s.kv = make(map[string]string)
+	s.expires = make(map[string]time.Time)
+	s.hashes = make(map[string]map[string]string)
+	s.zsets = make(map[string]map[string]int64)
+	s.queues = make(map[string][]string)
+}
+
+//DropConns closes every client connection, as a server restart would,
+//the data is kept
+func (s *Server) DropConns() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+}
+
+//Close stops the server and closes all client connections
+func (s *Server) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	err := s.listener.Close()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			c.Close()
+			return
+		}
+		s.conns[c] = struct{}{}
+		s.lock.Unlock()
+		s.wg.Add(1)
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, c)
+		s.lock.Unlock()
+		c.Close()
+	}()
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	authed := false
+	for {
+		req, err := readRequest(r)
+		if err != nil {
+			return
+		}
+		if len(req) == 0 {
+			continue
+		}
+		writeReply(w, s.exec(req, &authed))
+		//answer a pipelined batch with one write
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+//reads one request, a list of blocks terminated by an empty line
+func readRequest(r *bufio.Reader) ([]string, error) {
+	var req []string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = line[:len(line)-1]
+		if len(line) > 0 && line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+		if line == "" {
+			return req, nil
+		}
+		size, err := strconv.Atoi(line)
+		if err != nil || size < 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		block := make([]byte, size)
+		if _, err := io.ReadFull(r, block); err != nil {
+			return nil, err
+		}
+		if b, err := r.ReadByte(); err != nil {
+			return nil, err
+		} else if b == '\r' {
+			if _, err := r.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		req = append(req, string(block))
+	}
+}
+
+func writeReply(w *bufio.Writer, rsp []string) {
+	for _, b := range rsp {
+		w.WriteString(strconv.Itoa(len(b)))
+		w.WriteByte('\n')
+		w.WriteString(b)
+		w.WriteByte('\n')
+	}
+	w.WriteByte('\n')
+}
+
+//runs req for a connection, authed tracks whether it passed auth
+func (s *Server) exec(req []string, authed *bool) []string {
+	if req[0] == "auth" {
+		return s.auth(req[1:], authed)
+	}
+	cmd, ok := commands[req[0]]
+	if !ok {
+		return []string{"client_error", "Unknown Command: " + req[0]}
+	}
+	if len(req)-1 < cmd.minArgs || (cmd.pairs && (len(req)-1-cmd.minArgs)%2 != 0) {
+		return []string{"client_error", "wrong number of arguments"}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.password != "" && !*authed {
+		return []string{"noauth", "authentication required"}
+	}
+	s.calls++
+	s.purge()
+	return cmd.proc(s, req[1:])
+}
+
+func (s *Server) auth(args []string, authed *bool) []string {
+	if len(args) != 1 {
+		return []string{"client_error", "wrong number of arguments"}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.password != "" && args[0] != s.password {
+		return []string{"error", "invalid password"}
+	}
+	*authed = true
+	return []string{"ok", "1"}
+}
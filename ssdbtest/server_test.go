@@ -0,0 +1,81 @@
+package ssdbtest_test
+
+import (
+	"errors"
+	"github.com/jiecao-fm/ssdb"
+	"github.com/jiecao-fm/ssdb/ssdbtest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func connect(t *testing.T) (*ssdbtest.Server, *ssdb.SSDB) {
+	srv, err := ssdbtest.NewServer()
+	if err != nil {
+		t.Fatalf("start server failed: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	db, err := ssdb.Connect(srv.Host(), srv.Port(), time.Second, time.Second, time.Second)
+	if err != nil {
+		t.Fatalf("connect to server failed: %v", err)
+	}
+	t.Cleanup(db.Close)
+	return srv, db
+}
+
+func TestRanges(t *testing.T) {
+	_, db := connect(t)
+	db.MultiSet([]string{"a", "1", "b", "2", "c", "3", "d", "4"})
+
+	keys, _ := db.Keys("a", "c", 10)
+	assert.Equal(t, []string{"b", "c"}, keys, "keys excludes start and includes end")
+	keys, _ = db.Keys("", "", 2)
+	assert.Equal(t, []string{"a", "b"}, keys, "keys limit")
+	m, _ := db.RScan("d", "b", 10)
+	assert.Equal(t, ssdb.Pairs{{Key: "c", Value: "3"}, {Key: "b", Value: "2"}}, m, "rscan excludes start and includes end")
+
+	for i, k := range []string{"x", "y", "z"} {
+		db.ZSet("z", k, int64(i%2))
+	}
+	ks, _ := db.ZKeys("z", "", 0, 1, 10)
+	assert.Equal(t, []string{"x", "z", "y"}, ks, "zkeys ordered by score then key")
+	ks, _ = db.ZKeys("z", "x", 0, 1, 10)
+	assert.Equal(t, []string{"z", "y"}, ks, "zkeys key_start")
+	n, _ := db.ZCount("z", 1, 1)
+	assert.Equal(t, 1, n, "zcount")
+
+	for _, v := range []string{"0", "1", "2", "3"} {
+		db.QPushBack("q", v)
+	}
+	vs, _ := db.QSlice("q", 1, -2)
+	assert.Equal(t, []string{"1", "2"}, vs, "qslice negative end")
+	v, _ := db.QGet("q", -1)
+	assert.Equal(t, "3", v, "qget negative index")
+}
+
+func TestStatuses(t *testing.T) {
+	srv, db := connect(t)
+	_, err := db.Get("missing")
+	assert.True(t, errors.Is(err, ssdb.ErrNotFound), "get missing key")
+	_, err = db.QPopFront("missing")
+	assert.True(t, errors.Is(err, ssdb.ErrNotFound), "pop empty queue")
+	_, err = db.HGet("missing", "k")
+	assert.True(t, errors.Is(err, ssdb.ErrNotFound), "hget missing hash")
+
+	ok, _ := db.HSet("h", "k", "v")
+	assert.True(t, ok, "hset new field")
+	ok, _ = db.HSet("h", "k", "v2")
+	assert.False(t, ok, "hset existing field")
+	ok, _ = db.HDel("h", "k")
+	assert.True(t, ok, "hdel existing field")
+	names, _ := db.HList("", "", 10)
+	assert.Equal(t, []string{}, names, "empty hash still listed")
+
+	db.Set("k", "v")
+	_, err = db.Incr("k", 1)
+	assert.True(t, errors.Is(err, ssdb.ErrServerError), "incr non integer")
+
+	srv.Reset()
+	_, err = db.Get("k")
+	assert.True(t, errors.Is(err, ssdb.ErrNotFound), "reset kept data")
+}